@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"mbsop/mbs"
+)
+
+// VersionLink is a dated link to an MBS version page on the downloads page
+type VersionLink struct {
+	Date time.Time
+	Link string
+}
+
+// findVersionLinks returns every dated version link on the downloads page, oldest first.
+// Links repeated for the same month are only returned once.
+func findVersionLinks(doc *goquery.Document, baseURL string) []VersionLink {
+	seen := make(map[string]bool)
+	var versions []VersionLink
+
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+		date, ok := mbs.VersionMonth(s.Text())
+		if !ok {
+			return
+		}
+		link := mbs.ResolveLink(href, baseURL)
+		if seen[link] {
+			return
+		}
+		seen[link] = true
+		versions = append(versions, VersionLink{Date: date, Link: link})
+	})
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Date.Before(versions[j].Date)
+	})
+	return versions
+}
+
+// versionResult is the outcome of fetching, downloading and converting one version in a worker
+type versionResult struct {
+	action string
+	result ConversionResult
+	record RunRecord
+	err    error
+}
+
+// versionClaims records the MBS dates claimed by workers, so two version links that resolve
+// to the same XML are never converted and written at the same time
+type versionClaims struct {
+	mu    sync.Mutex
+	dates map[string]bool
+}
+
+// claim reports whether mbsDate was not already claimed, claiming it if so
+func (c *versionClaims) claim(mbsDate string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dates[mbsDate] {
+		return false
+	}
+	c.dates[mbsDate] = true
+	return true
+}
+
+// downloadAllVersions downloads and converts every version on the downloads page released in or
+// after since (all versions when since is zero) that is not already in the downloads directory.
+// Up to config.concurrency versions are downloaded and converted at once, but their sinks and run
+// log records are handled one at a time oldest first, so each changelog entry diffs against its
+// predecessor. Failures are logged and the crawl continues; an error is returned only if every
+// attempted version failed, or errSchemaDrift if -strict-schema is set and any version drifted.
+func downloadAllVersions(ctx context.Context, config Config, requiredSinks map[string]bool, doc *goquery.Document, since time.Time) error {
+	versions := findVersionLinks(doc, config.baseURL)
+	if len(versions) == 0 {
+		return withExitCode(exitParse, fmt.Errorf("no version links found on the downloads page"))
+	}
+
+	var pending []VersionLink
+	for _, version := range versions {
+		if since.IsZero() || !version.Date.Before(since) {
+			pending = append(pending, version)
+		}
+	}
+
+	// Each version has its own buffered channel so workers never wait for the delivery loop
+	results := make([]chan versionResult, len(pending))
+	for i := range results {
+		results[i] = make(chan versionResult, 1)
+	}
+	claims := &versionClaims{dates: make(map[string]bool)}
+	slots := make(chan struct{}, max(config.concurrency, 1))
+	go func() {
+		for i, version := range pending {
+			slots <- struct{}{}
+			go func() {
+				defer func() { <-slots }()
+				results[i] <- convertVersion(ctx, config, version, claims)
+			}()
+		}
+	}()
+
+	var attempted, downloaded, skipped int
+	var failed, drifted []string
+	for i, version := range pending {
+		res := <-results[i]
+		month := version.Date.Format("January 2006")
+		if res.err == nil && res.action == "downloaded" {
+			err := deliverVersion(ctx, config, requiredSinks, res.result, &res.record)
+			switch {
+			case errors.Is(err, errSchemaDrift):
+				drifted = append(drifted, month)
+			case err != nil:
+				res.action, res.err = "failed", err
+			}
+		}
+
+		switch {
+		case res.err != nil:
+			slog.Error("Failed to process version", "month", month, "url", version.Link, "mbs_date", res.record.MBSDate, "error", res.err)
+			res.record.Errors = append(res.record.Errors, res.err.Error())
+			attempted++
+			failed = append(failed, month)
+		case res.action == "skipped":
+			skipped++
+		default:
+			attempted++
+			downloaded++
+		}
+		res.record.Action = res.action
+		writeRunLog(config, res.record)
+	}
+
+	slog.Info("Processed all versions", "downloaded", downloaded, "skipped", skipped, "failed", len(failed))
+	if len(failed) > 0 {
+		slog.Info("Failed versions", "months", strings.Join(failed, ", "))
+	}
+	if attempted > 0 && len(failed) == attempted {
+		return fmt.Errorf("all %d versions failed", len(failed))
+	}
+	if len(drifted) > 0 {
+		slog.Info("Schema drift detected", "months", strings.Join(drifted, ", "))
+		return errSchemaDrift
+	}
+	return nil
+}
+
+// convertVersion fetches a version page, then downloads and converts its XML unless it is already
+// present or another worker has claimed the same MBS date. It is safe to run concurrently.
+func convertVersion(ctx context.Context, config Config, version VersionLink, claims *versionClaims) versionResult {
+	res := versionResult{action: "failed", record: RunRecord{Action: "failed"}}
+
+	downloadDoc, err := fetchPage(ctx, version.Link, config)
+	if err != nil {
+		res.err = fmt.Errorf("failed to fetch download page: %w", err)
+		return res
+	}
+
+	xmlLink := mbs.FindXMLLink(downloadDoc, config.baseURL)
+	if xmlLink == "" {
+		res.err = fmt.Errorf("could not find XML download link")
+		return res
+	}
+
+	mbsDate, err := mbs.XMLLinkDate(xmlLink)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.record.MBSDate = mbsDate
+
+	if !claims.claim(mbsDate) {
+		slog.Info("MBS version is linked more than once, skipping duplicate", "mbs_date", mbsDate)
+		res.action = "skipped"
+		return res
+	}
+
+	hasVersion, err := hasLatestVersion(mbsDate)
+	if err != nil {
+		res.err = fmt.Errorf("failed to check for existing version: %w", err)
+		return res
+	}
+	if hasVersion && !config.force {
+		slog.Info("Already have this MBS version, skipping download", "mbs_date", mbsDate)
+		res.action = "skipped"
+		return res
+	}
+
+	result, err := downloadAndConvertXML(ctx, xmlLink, config)
+	if err != nil {
+		res.err = fmt.Errorf("failed to process XML: %w", err)
+		return res
+	}
+	res.record.TotalItems = result.Summary.TotalItems
+	res.record.ValidItems = result.Summary.ValidItems
+	res.action = "downloaded"
+	res.result = result
+	return res
+}
+
+// deliverVersion writes the stats file and runs the sinks for a converted version, filling in the run record.
+// With -strict-schema, a drifted version is reported as completeCycle does and errSchemaDrift returned.
+func deliverVersion(ctx context.Context, config Config, requiredSinks map[string]bool, result ConversionResult, record *RunRecord) error {
+	mbsDate := record.MBSDate
+	if config.statsFile != "" {
+		if err := writeStatsFile(config.statsFile, mbsDate, result.Summary); err != nil {
+			return err
+		}
+	}
+
+	sinkErrors, failedRequired := deliverSinks(buildSinks(ctx, config, requiredSinks, mbsDate, result))
+	record.Errors = append(record.Errors, sinkErrors...)
+	if len(failedRequired) > 0 {
+		return fmt.Errorf("required sinks failed: %s", strings.Join(failedRequired, ", "))
+	}
+	if result.Summary.SchemaDrifted() {
+		if !config.strictSchema {
+			record.Errors = append(record.Errors, errSchemaDrift.Error())
+			return nil
+		}
+		reportSchemaDrift(ctx, config, mbsDate, result.Summary, record)
+		return errSchemaDrift
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mbsop/mbs"
+)
+
+func TestDeliverVersionSchemaDrift(t *testing.T) {
+	var notified map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&notified)
+	}))
+	defer server.Close()
+
+	result := ConversionResult{Summary: mbs.ValidationSummary{UnknownFields: []string{"NewColumn"}}}
+
+	// Without -strict-schema the drift is only recorded
+	record := RunRecord{MBSDate: "20250101"}
+	if err := deliverVersion(context.Background(), Config{schemaDriftWebhook: server.URL}, nil, result, &record); err != nil {
+		t.Fatalf("deliverVersion without -strict-schema: %v", err)
+	}
+	if notified != nil {
+		t.Errorf("schema drift webhook sent without -strict-schema")
+	}
+
+	config := Config{strictSchema: true, schemaDriftWebhook: server.URL}
+	record = RunRecord{MBSDate: "20250101"}
+	if err := deliverVersion(context.Background(), config, nil, result, &record); !errors.Is(err, errSchemaDrift) {
+		t.Fatalf("deliverVersion with -strict-schema = %v, want errSchemaDrift", err)
+	}
+	if notified["status"] != "schema_drift" || notified["mbs_date"] != "20250101" {
+		t.Errorf("schema drift webhook body = %v", notified)
+	}
+	if len(record.Errors) != 1 || record.Errors[0] != errSchemaDrift.Error() {
+		t.Errorf("record errors = %q, want [%q]", record.Errors, errSchemaDrift.Error())
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const changelogName = "CHANGELOG.md"
+
+// notableFeeChanges is the number of largest ScheduleFee changes listed in a changelog entry
+const notableFeeChanges = 10
+
+// FieldChange holds the old and new value of a field that differs between versions
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// ChangedItem lists the fields that differ for an item present in both versions
+type ChangedItem struct {
+	ItemNum string                 `json:"ItemNum"`
+	Fields  map[string]FieldChange `json:"fields"`
+}
+
+// VersionDiff is the item-level comparison of two MBS versions, keyed by ItemNum
+type VersionDiff struct {
+	Added   []map[string]interface{} `json:"added"`
+	Removed []map[string]interface{} `json:"removed"`
+	Changed []ChangedItem            `json:"changed"`
+}
+
+// indexItems maps items by ItemNum, ignoring items without one
+func indexItems(items []map[string]interface{}) map[string]map[string]interface{} {
+	index := make(map[string]map[string]interface{})
+	for _, item := range items {
+		if itemNum, ok := item["ItemNum"].(string); ok && itemNum != "" {
+			index[itemNum] = item
+		}
+	}
+	return index
+}
+
+// sortedItemNums returns the keys of an item index in item number order
+func sortedItemNums(index map[string]map[string]interface{}) []string {
+	var itemNums []string
+	for itemNum := range index {
+		itemNums = append(itemNums, itemNum)
+	}
+	sort.Slice(itemNums, func(i, j int) bool {
+		return lessItemNum(itemNums[i], itemNums[j])
+	})
+	return itemNums
+}
+
+// valuesEqual compares typed values, treating all numeric representations of a number as equal
+func valuesEqual(a, b interface{}) bool {
+	fa, aIsNum := toFloat(a)
+	fb, bIsNum := toFloat(b)
+	if aIsNum || bIsNum {
+		return aIsNum && bIsNum && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat returns the numeric value of a typed field value
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// diffVersions compares the items of two versions and reports added, removed and changed items
+func diffVersions(oldItems, newItems []map[string]interface{}) VersionDiff {
+	oldIndex := indexItems(oldItems)
+	newIndex := indexItems(newItems)
+	diff := VersionDiff{
+		Added:   []map[string]interface{}{},
+		Removed: []map[string]interface{}{},
+		Changed: []ChangedItem{},
+	}
+
+	for _, itemNum := range sortedItemNums(newIndex) {
+		newItem := newIndex[itemNum]
+		oldItem, exists := oldIndex[itemNum]
+		if !exists {
+			diff.Added = append(diff.Added, newItem)
+			continue
+		}
+
+		fields := make(map[string]FieldChange)
+		for field, newValue := range newItem {
+			if oldValue := oldItem[field]; !valuesEqual(oldValue, newValue) {
+				fields[field] = FieldChange{Old: oldValue, New: newValue}
+			}
+		}
+		for field, oldValue := range oldItem {
+			if _, exists := newItem[field]; !exists && oldValue != nil {
+				fields[field] = FieldChange{Old: oldValue, New: nil}
+			}
+		}
+		if len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ChangedItem{ItemNum: itemNum, Fields: fields})
+		}
+	}
+
+	for _, itemNum := range sortedItemNums(oldIndex) {
+		if _, exists := newIndex[itemNum]; !exists {
+			diff.Removed = append(diff.Removed, oldIndex[itemNum])
+		}
+	}
+
+	return diff
+}
+
+// previousVersion returns the newest archived version older than mbsDate
+func previousVersion(mbsDate string) (ArchivedVersion, bool, error) {
+	versions, err := listArchivedVersions()
+	if err != nil {
+		return ArchivedVersion{}, false, err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Date < mbsDate {
+			return versions[i], true, nil
+		}
+	}
+	return ArchivedVersion{}, false, nil
+}
+
+// formatMBSDate renders a YYYYMMDD date as YYYY-MM-DD, leaving anything unparseable as is
+func formatMBSDate(mbsDate string) string {
+	if t, err := time.Parse("20060102", mbsDate); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return mbsDate
+}
+
+// changelogEntry renders the markdown section for a new version
+func changelogEntry(mbsDate string, items []map[string]interface{}, previous *ArchivedVersion, diff VersionDiff) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", formatMBSDate(mbsDate))
+
+	if previous == nil {
+		fmt.Fprintf(&b, "Initial version with %d items. No previous version to compare against.\n\n", len(items))
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "Compared with %s: added %d, removed %d, changed %d (%d items in total).\n\n",
+		formatMBSDate(previous.Date), len(diff.Added), len(diff.Removed), len(diff.Changed), len(items))
+
+	// List the largest ScheduleFee movements
+	var feeChanges []ChangedItem
+	for _, changed := range diff.Changed {
+		if _, ok := changed.Fields["ScheduleFee"]; ok {
+			feeChanges = append(feeChanges, changed)
+		}
+	}
+	if len(feeChanges) == 0 {
+		return b.String()
+	}
+
+	delta := func(changed ChangedItem) float64 {
+		oldFee, _ := toFloat(changed.Fields["ScheduleFee"].Old)
+		newFee, _ := toFloat(changed.Fields["ScheduleFee"].New)
+		return math.Abs(newFee - oldFee)
+	}
+	sort.SliceStable(feeChanges, func(i, j int) bool {
+		return delta(feeChanges[i]) > delta(feeChanges[j])
+	})
+
+	fmt.Fprintf(&b, "Notable fee changes (ScheduleFee changed on %d items):\n\n", len(feeChanges))
+	for i, changed := range feeChanges {
+		if i == notableFeeChanges {
+			break
+		}
+		fee := changed.Fields["ScheduleFee"]
+		fmt.Fprintf(&b, "- Item %s: %s → %s\n", changed.ItemNum, formatCSVNumber(fee.Old), formatCSVNumber(fee.New))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// appendChangelog adds a dated section for the new version to CHANGELOG.md in the downloads directory,
+// comparing it against the newest older archived JSON version when one exists
+func appendChangelog(mbsDate string, items []map[string]interface{}) error {
+	path := filepath.Join(downloadPath, changelogName)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read changelog: %w", err)
+	}
+	heading := fmt.Sprintf("## %s\n", formatMBSDate(mbsDate))
+	if strings.Contains(string(existing), heading) {
+		return nil // This version has already been recorded
+	}
+
+	previous, found, err := previousVersion(mbsDate)
+	if err != nil {
+		return err
+	}
+
+	var entry string
+	if found {
+		oldItems, err := loadItems(previous.Path)
+		if err != nil {
+			return err
+		}
+		entry = changelogEntry(mbsDate, items, &previous, diffVersions(oldItems, items))
+	} else {
+		entry = changelogEntry(mbsDate, items, nil, VersionDiff{})
+	}
+
+	if len(existing) == 0 {
+		entry = "# MBS Changelog\n\n" + entry
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open changelog: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(entry); err != nil {
+		return fmt.Errorf("failed to write changelog: %w", err)
+	}
+	return nil
+}
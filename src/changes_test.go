@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffVersions(t *testing.T) {
+	oldItems := []map[string]interface{}{
+		{"ItemNum": "23", "ScheduleFee": 41.4},
+		{"ItemNum": "104", "ScheduleFee": 100.0},
+		{"ItemNum": "105", "ScheduleFee": 50.0},
+	}
+	newItems := []map[string]interface{}{
+		{"ItemNum": "3", "ScheduleFee": 19.6},
+		{"ItemNum": "23", "ScheduleFee": 42.85},
+		{"ItemNum": "105", "ScheduleFee": 50.0},
+	}
+
+	diff := diffVersions(oldItems, newItems)
+	if len(diff.Added) != 1 || diff.Added[0]["ItemNum"] != "3" {
+		t.Errorf("Added = %v, want item 3", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0]["ItemNum"] != "104" {
+		t.Errorf("Removed = %v, want item 104", diff.Removed)
+	}
+	want := []ChangedItem{{ItemNum: "23", Fields: map[string]FieldChange{"ScheduleFee": {Old: 41.4, New: 42.85}}}}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("Changed = %+v, want %+v", diff.Changed, want)
+	}
+}
+
+func TestDiffVersionsEqualNumbers(t *testing.T) {
+	// The same fee written as 100 and 100.0, or as int64, is not a change
+	var oldItems, newItems []map[string]interface{}
+	json.Unmarshal([]byte(`[{"ItemNum": "104", "ScheduleFee": 100}]`), &oldItems)
+	json.Unmarshal([]byte(`[{"ItemNum": "104", "ScheduleFee": 100.0}]`), &newItems)
+	if diff := diffVersions(oldItems, newItems); len(diff.Changed) != 0 {
+		t.Errorf("100 vs 100.0 reported as changed: %+v", diff.Changed)
+	}
+
+	oldItems = []map[string]interface{}{{"ItemNum": "104", "ScheduleFee": int64(100)}}
+	newItems = []map[string]interface{}{{"ItemNum": "104", "ScheduleFee": 100.0}}
+	if diff := diffVersions(oldItems, newItems); len(diff.Changed) != 0 {
+		t.Errorf("int64 100 vs float 100.0 reported as changed: %+v", diff.Changed)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"mbsop/mbs"
+)
+
+const checkpointName = "conversion.checkpoint"
+
+// checkpointLine is one line of the checkpoint file. The first line carries the source and
+// options hashes, item lines carry a converted item and progress lines mark everything before Next as done,
+// along with the number of items skipped so far for each reason.
+type checkpointLine struct {
+	SourceHash  string                 `json:"source_hash,omitempty"`
+	OptionsHash string                 `json:"options_hash,omitempty"`
+	Item        map[string]interface{} `json:"item,omitempty"`
+	Next        *int                   `json:"next,omitempty"`
+	SkipReasons map[string]int         `json:"skip_reasons,omitempty"`
+}
+
+// conversionCheckpoint periodically flushes converted items to disk so an interrupted
+// conversion of the same source can resume instead of starting over
+type conversionCheckpoint struct {
+	path       string
+	every      int
+	file       *os.File
+	writer     *bufio.Writer
+	resumeFrom int                      // Index of the first item still to be converted
+	resumed    []map[string]interface{} // Items converted before the checkpoint was taken
+	skips      map[string]int           // Items skipped before the checkpoint was taken, by reason
+	opts       mbs.Options              // Conversion options the items are converted with
+}
+
+// checkpointOptionsHash identifies the conversion options a checkpoint was written with, so items
+// converted with different flags (e.g. -money-as-cents) are never mixed in one output
+func checkpointOptionsHash(opts mbs.Options) string {
+	opts.Checkpoint = nil
+	opts.Hooks = mbs.Hooks{}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", opts)))
+	return hex.EncodeToString(sum[:])
+}
+
+// openCheckpoint loads any checkpoint left for the same source hash and conversion options and starts a fresh
+// checkpoint file containing the resumed items. It returns nil when checkpointing is disabled.
+func openCheckpoint(config Config, sourceHash string) (*conversionCheckpoint, error) {
+	if !config.checkpoint {
+		return nil, nil
+	}
+
+	cp := &conversionCheckpoint{
+		path:  filepath.Join(downloadPath, checkpointName),
+		every: config.checkpointEvery,
+		opts:  conversionOptions(config),
+	}
+	if cp.every < 1 {
+		cp.every = 1
+	}
+	optionsHash := checkpointOptionsHash(cp.opts)
+	cp.load(sourceHash, optionsHash)
+
+	file, err := os.Create(cp.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	cp.file = file
+	cp.writer = bufio.NewWriter(file)
+
+	// Rewrite the resumed state so a partially written tail is never carried forward
+	if err := cp.write(checkpointLine{SourceHash: sourceHash, OptionsHash: optionsHash}); err != nil {
+		cp.close()
+		return nil, err
+	}
+	for _, item := range cp.resumed {
+		if err := cp.Add(item); err != nil {
+			cp.close()
+			return nil, err
+		}
+	}
+	if err := cp.mark(cp.resumeFrom, cp.skips); err != nil {
+		cp.close()
+		return nil, err
+	}
+
+	if cp.resumeFrom > 0 {
+		log.Printf("Resuming conversion from checkpoint at item %d (%d items already converted)", cp.resumeFrom, len(cp.resumed))
+	}
+	return cp, nil
+}
+
+// load reads an existing checkpoint, keeping only items covered by the last progress marker.
+// A checkpoint for a different source or written with different options is discarded.
+func (cp *conversionCheckpoint) load(sourceHash, optionsHash string) {
+	file, err := os.Open(cp.path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+
+	if !scanner.Scan() {
+		return
+	}
+	var header checkpointLine
+	if err := decodeCheckpointLine(scanner.Bytes(), &header); err != nil || header.SourceHash != sourceHash {
+		log.Printf("Discarding checkpoint that does not match the current source")
+		return
+	}
+	if header.OptionsHash != optionsHash {
+		log.Printf("Discarding checkpoint written with different conversion options")
+		return
+	}
+
+	var pending []map[string]interface{}
+	for scanner.Scan() {
+		var line checkpointLine
+		if err := decodeCheckpointLine(scanner.Bytes(), &line); err != nil {
+			break // Partially written line from an interrupted run
+		}
+		switch {
+		case line.Item != nil:
+			cp.restoreNumbers(line.Item)
+			pending = append(pending, line.Item)
+		case line.Next != nil:
+			cp.resumed = append(cp.resumed, pending...)
+			cp.resumeFrom = *line.Next
+			cp.skips = line.SkipReasons
+			pending = nil
+		}
+	}
+}
+
+// decodeCheckpointLine decodes a line keeping numbers as json.Number, so integer cents are not
+// rounded through float64
+func decodeCheckpointLine(data []byte, line *checkpointLine) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(line)
+}
+
+// restoreNumbers converts the numbers of a resumed item back to the types the conversion
+// produced: int64 for monetary fields with -money-as-cents and float64 otherwise
+func (cp *conversionCheckpoint) restoreNumbers(item map[string]interface{}) {
+	for field, value := range item {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if cp.opts.MoneyAsCents && mbs.IsMonetary(field) {
+			if cents, err := number.Int64(); err == nil {
+				item[field] = cents
+				continue
+			}
+		}
+		if f, err := number.Float64(); err == nil {
+			item[field] = f
+		}
+	}
+}
+
+// write encodes a single line into the checkpoint buffer
+func (cp *conversionCheckpoint) write(line checkpointLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if _, err := cp.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Resume returns the index to resume from, the items converted before it and the skip counts
+func (cp *conversionCheckpoint) Resume() (int, []map[string]interface{}, map[string]int) {
+	return cp.resumeFrom, cp.resumed, cp.skips
+}
+
+// Add records a converted item
+func (cp *conversionCheckpoint) Add(item map[string]interface{}) error {
+	return cp.write(checkpointLine{Item: item})
+}
+
+// mark records that every item before next has been processed and flushes to disk
+func (cp *conversionCheckpoint) mark(next int, skipReasons map[string]int) error {
+	if err := cp.write(checkpointLine{Next: &next, SkipReasons: skipReasons}); err != nil {
+		return err
+	}
+	if err := cp.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush checkpoint: %w", err)
+	}
+	return cp.file.Sync()
+}
+
+// Progress takes a checkpoint every cp.every items
+func (cp *conversionCheckpoint) Progress(next int, skipReasons map[string]int) error {
+	if next == cp.resumeFrom || next%cp.every != 0 {
+		return nil
+	}
+	return cp.mark(next, skipReasons)
+}
+
+// close closes the checkpoint file, leaving it on disk for a later resume
+func (cp *conversionCheckpoint) close() {
+	cp.file.Close()
+}
+
+// remove closes and deletes the checkpoint once the output has been written
+func (cp *conversionCheckpoint) remove() {
+	cp.close()
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove checkpoint file: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointResumeKeepsSkipReasons(t *testing.T) {
+	downloadPath = t.TempDir()
+	config := Config{checkpoint: true, checkpointEvery: 2}
+
+	cp, err := openCheckpoint(config, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Add(map[string]interface{}{"ItemNum": "23"}); err != nil {
+		t.Fatal(err)
+	}
+	skips := map[string]int{"required field 'ItemNum' is empty": 1}
+	if err := cp.Progress(2, skips); err != nil {
+		t.Fatal(err)
+	}
+	cp.close()
+
+	resumed, err := openCheckpoint(config, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.remove()
+	next, items, gotSkips := resumed.Resume()
+	if next != 2 || len(items) != 1 || items[0]["ItemNum"] != "23" {
+		t.Errorf("Resume() = %d, %v, want 2 and item 23", next, items)
+	}
+	if !reflect.DeepEqual(gotSkips, skips) {
+		t.Errorf("resumed skip reasons = %v, want %v", gotSkips, skips)
+	}
+}
+
+func TestCheckpointDiscardedWhenOptionsChange(t *testing.T) {
+	downloadPath = t.TempDir()
+	config := Config{checkpoint: true, checkpointEvery: 1}
+
+	cp, err := openCheckpoint(config, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Add(map[string]interface{}{"ItemNum": "23", "ScheduleFee": 42.85}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Progress(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	cp.close()
+
+	// The same source converted with -money-as-cents must not reuse items converted to dollars
+	config.moneyAsCents = true
+	resumed, err := openCheckpoint(config, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.remove()
+	if next, items, _ := resumed.Resume(); next != 0 || len(items) != 0 {
+		t.Errorf("Resume() after changing -money-as-cents = %d, %v, want a fresh start", next, items)
+	}
+}
+
+func TestCheckpointKeepsNumberTypes(t *testing.T) {
+	downloadPath = t.TempDir()
+	config := Config{checkpoint: true, checkpointEvery: 1, moneyAsCents: true}
+
+	cp, err := openCheckpoint(config, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := map[string]interface{}{"ItemNum": "23", "ScheduleFee": int64(4285), "BasicUnits": 3.5}
+	if err := cp.Add(item); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Progress(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	cp.close()
+
+	resumed, err := openCheckpoint(config, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.remove()
+	_, items, _ := resumed.Resume()
+	if len(items) != 1 {
+		t.Fatalf("resumed %d items, want 1", len(items))
+	}
+	if !reflect.DeepEqual(items[0], item) {
+		t.Errorf("resumed item = %#v, want %#v", items[0], item)
+	}
+}
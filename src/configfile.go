@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile parses a -config file into a map keyed by flag name.
+// Files ending in .json are parsed as JSON and anything else as YAML.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&values)
+	} else {
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// configValue renders a config file value as a flag argument. Lists are joined with commas and
+// objects, such as webhook-headers, are encoded as JSON.
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, element := range v {
+			part, err := configValue(element)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// applyConfigFile sets every flag named in the -config file that was not given on the command
+// line, so command-line flags override file values. Unknown keys are reported as an error.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var unknown []string
+	for key := range values {
+		if fs.Lookup(key) == nil || key == "config" {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keys in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	for key, value := range values {
+		if explicit[key] {
+			continue
+		}
+		arg, err := configValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s in config file: %w", key, err)
+		}
+		if err := fs.Set(key, arg); err != nil {
+			return fmt.Errorf("invalid value for %s in config file: %w", key, err)
+		}
+	}
+	return nil
+}
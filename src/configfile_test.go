@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFlagSet returns a flag set with a few of the program's flags, for parsing command lines
+// against a config file
+func testFlagSet(config *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("mbs", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&config.configFile, "config", "", "")
+	fs.StringVar(&config.format, "format", "json", "")
+	fs.IntVar(&config.retries, "retries", 3, "")
+	fs.BoolVar(&config.force, "force", false, "")
+	fs.StringVar(&config.webhookHeaders, "webhook-headers", "", "")
+	fs.Var(&config.filterCategory, "filter-category", "")
+	return fs
+}
+
+// writeConfigFile writes a config file with the given name to a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfigFileYAML(t *testing.T) {
+	path := writeConfigFile(t, "mbs.yaml", `
+format: csv
+retries: 5
+force: true
+webhook-headers:
+  Authorization: Bearer token
+  X-API-Key: key
+filter-category: [1, 3]
+`)
+	var config Config
+	fs := testFlagSet(&config)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(fs, path); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+
+	if config.format != "csv" || config.retries != 5 || !config.force {
+		t.Errorf("format, retries, force = %q, %d, %v, want csv, 5, true", config.format, config.retries, config.force)
+	}
+	if !reflect.DeepEqual([]string(config.filterCategory), []string{"1", "3"}) {
+		t.Errorf("filter-category = %q, want [1 3] from the YAML list", config.filterCategory)
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(config.webhookHeaders), &headers); err != nil {
+		t.Fatalf("webhook-headers %q is not JSON: %v", config.webhookHeaders, err)
+	}
+	want := map[string]string{"Authorization": "Bearer token", "X-API-Key": "key"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("webhook-headers = %v, want %v", headers, want)
+	}
+}
+
+func TestApplyConfigFileCommandLineWins(t *testing.T) {
+	path := writeConfigFile(t, "mbs.json", `{"format": "csv", "retries": 5}`)
+	var config Config
+	fs := testFlagSet(&config)
+	if err := fs.Parse([]string{"-format", "ndjson"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(fs, path); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	if config.format != "ndjson" {
+		t.Errorf("format = %q, want the command-line value ndjson", config.format)
+	}
+	if config.retries != 5 {
+		t.Errorf("retries = %d, want 5 from the config file", config.retries)
+	}
+}
+
+func TestApplyConfigFileUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "mbs.yaml", "format: csv\nretrys: 5\nconfig: other.yaml\n")
+	var config Config
+	fs := testFlagSet(&config)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	err := applyConfigFile(fs, path)
+	if err == nil || !strings.Contains(err.Error(), "unknown keys in config file") || !strings.Contains(err.Error(), "config, retrys") {
+		t.Errorf("applyConfigFile = %v, want an error naming config and retrys", err)
+	}
+	if config.format != "json" {
+		t.Errorf("format = %q after a rejected config file, want it left at the default", config.format)
+	}
+}
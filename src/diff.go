@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// mbsDateRegex matches a version given by its YYYYMMDD date rather than a file path
+var mbsDateRegex = regexp.MustCompile(`^\d{8}$`)
+
+// DiffReport is the -diff output: the two versions compared and their item-level differences
+type DiffReport struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+	VersionDiff
+}
+
+// resolveVersionPath returns the JSON file for a -diff argument, which is either a path
+// or the YYYYMMDD date of an archived version in the downloads directory. A date resolves to
+// the gzipped file when only that one exists.
+func resolveVersionPath(version string) string {
+	if mbsDateRegex.MatchString(version) {
+		path := filepath.Join(downloadPath, fmt.Sprintf("mbs_%s.json", version))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, err := os.Stat(path + ".gz"); err == nil {
+				return path + ".gz"
+			}
+		}
+		return path
+	}
+	return version
+}
+
+// buildDiffReport loads the two versions named in a -diff argument ("OLD,NEW") and compares them
+func buildDiffReport(arg string) (DiffReport, error) {
+	parts := strings.Split(arg, ",")
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return DiffReport{}, fmt.Errorf("expected OLD,NEW (JSON file paths or YYYYMMDD dates), got %q", arg)
+	}
+	oldPath := resolveVersionPath(strings.TrimSpace(parts[0]))
+	newPath := resolveVersionPath(strings.TrimSpace(parts[1]))
+
+	oldItems, err := loadItems(oldPath)
+	if err != nil {
+		return DiffReport{}, err
+	}
+	newItems, err := loadItems(newPath)
+	if err != nil {
+		return DiffReport{}, err
+	}
+
+	return DiffReport{
+		Old:         oldPath,
+		New:         newPath,
+		VersionDiff: diffVersions(oldItems, newItems),
+	}, nil
+}
+
+// writeDiffReport writes the report as indented JSON, or as readable text when format is "text"
+func writeDiffReport(w io.Writer, report DiffReport, format string) error {
+	if format == "text" {
+		_, err := io.WriteString(w, diffText(report))
+		return err
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to format diff: %w", err)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// diffText renders a report as a plain text summary listing every added, removed and changed item
+func diffText(report DiffReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Comparing %s → %s\n", report.Old, report.New)
+
+	fmt.Fprintf(&b, "\nAdded (%d):\n", len(report.Added))
+	for _, item := range report.Added {
+		fmt.Fprintf(&b, "  + %s\n", itemLabel(item))
+	}
+
+	fmt.Fprintf(&b, "\nRemoved (%d):\n", len(report.Removed))
+	for _, item := range report.Removed {
+		fmt.Fprintf(&b, "  - %s\n", itemLabel(item))
+	}
+
+	fmt.Fprintf(&b, "\nChanged (%d):\n", len(report.Changed))
+	for _, changed := range report.Changed {
+		fmt.Fprintf(&b, "  ~ %s\n", changed.ItemNum)
+		var fields []string
+		for field := range changed.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			change := changed.Fields[field]
+			fmt.Fprintf(&b, "      %s: %s → %s\n", field, diffValue(change.Old), diffValue(change.New))
+		}
+	}
+	return b.String()
+}
+
+// itemLabel identifies an item in text output by its ItemNum and shortened description
+func itemLabel(item map[string]interface{}) string {
+	label := fmt.Sprint(item["ItemNum"])
+	if description := summarizeDescription(item["Description"]); description != "" {
+		label += " " + description
+	}
+	return label
+}
+
+// summarizeDescription shortens an item description to a single line for text output
+func summarizeDescription(value interface{}) string {
+	description, _ := value.(string)
+	description = strings.Join(strings.Fields(description), " ")
+	if runes := []rune(description); len(runes) > 80 {
+		description = string(runes[:77]) + "..."
+	}
+	return description
+}
+
+// diffValue renders a typed value for text output, showing null explicitly and quoting strings
+func diffValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", summarizeDescription(v))
+	default:
+		return formatCSVNumber(v)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandCommand(t *testing.T) {
+	result := ConversionResult{
+		OutputPath: "downloads/mbs_20250701.json",
+		XMLPath:    "downloads/mbs_20250701.xml",
+		Items:      make([]map[string]interface{}, 3),
+	}
+	tests := []struct {
+		template string
+		result   ConversionResult
+		want     string
+	}{
+		{"import.sh {file} {date} {count}", result, "import.sh downloads/mbs_20250701.json 20250701 3"},
+		{"cp {json} /data/{date}.json", result, "cp downloads/mbs_20250701.json /data/20250701.json"},
+		{"archive {xml}", result, "archive downloads/mbs_20250701.xml"},
+		{"archive {xml}", ConversionResult{OutputPath: "out.json"}, "archive "}, // Without -keep-xml
+		{"echo {unknown} {{date}}", result, "echo {unknown} {20250701}"},
+		{"notify.sh", result, "notify.sh"},
+	}
+	for _, tt := range tests {
+		if got := expandCommand(tt.template, "20250701", tt.result); got != tt.want {
+			t.Errorf("expandCommand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"import.sh {file} {date}", []string{"import.sh", "{file}", "{date}"}},
+		{"  spaced   out\targs  ", []string{"spaced", "out", "args"}},
+		{`"/opt/my scripts/import.sh" {file}`, []string{"/opt/my scripts/import.sh", "{file}"}},
+		{`'/opt/my scripts/import.sh' --label 'MBS $DATE'`, []string{"/opt/my scripts/import.sh", "--label", "MBS $DATE"}},
+		{`/opt/my\ scripts/import.sh`, []string{"/opt/my scripts/import.sh"}},
+		{`echo "say \"hi\" \n"`, []string{"echo", `say "hi" \n`}},
+		{`prefix"quoted part"suffix`, []string{"prefixquoted partsuffix"}},
+		{`empty "" ''`, []string{"empty", "", ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.command)
+		if err != nil {
+			t.Errorf("splitCommandLine(%q): %v", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandLineErrors(t *testing.T) {
+	for _, command := range []string{`echo "unterminated`, `echo 'unterminated`, `echo trailing\`} {
+		if words, err := splitCommandLine(command); err == nil {
+			t.Errorf("splitCommandLine(%q) = %q, want an error", command, words)
+		}
+	}
+}
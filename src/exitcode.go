@@ -0,0 +1,68 @@
+package main
+
+import "errors"
+
+// Exit codes let wrapper scripts tell failure categories apart without parsing the logs
+const (
+	exitFailure      = 1  // Any failure not covered by a more specific code, such as a file system error
+	exitUsage        = 2  // Invalid flags or flag combinations (also used by the flag package)
+	exitSchemaDrift  = 3  // -strict-schema is set and the fields differ from the field definitions
+	exitNetwork      = 4  // A page or the XML could not be fetched
+	exitParse        = 5  // The pages could not be scraped or the XML could not be converted
+	exitValidation   = 6  // The converted items failed validation
+	exitItemNotFound = 7  // -item named an ItemNum that is not in the latest version
+	exitNewVersion   = 10 // -dry-run found a version not yet downloaded
+	exitUpToDate     = 11 // The latest version is already downloaded, so nothing was done
+)
+
+// exitCodeHelp is appended to the flag help
+const exitCodeHelp = `
+Exit codes:
+  0   success: a new version was downloaded and processed (or -dry-run found nothing new)
+  1   other failure, such as a file system or required sink error
+  2   invalid flags
+  3   schema drift detected with -strict-schema
+  4   network error fetching a page or the XML
+  5   scraping or XML conversion error
+  6   validation error, such as too many skipped items, or a file failing -verify-manifest
+  7   -item not found in the latest version
+  10  -dry-run found a new version
+  11  already up to date, nothing downloaded
+`
+
+// errUpToDate is returned by runCycle when the latest version is already downloaded
+var errUpToDate = errors.New("already up to date")
+
+// exitError attaches an exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode marks err as a failure that should exit with code
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the process exit code for an error returned by run
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errNewVersion):
+		return exitNewVersion
+	case errors.Is(err, errUpToDate):
+		return exitUpToDate
+	case errors.Is(err, errSchemaDrift):
+		return exitSchemaDrift
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailure
+}
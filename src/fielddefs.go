@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"mbsop/mbs"
+)
+
+// loadFieldDefinitions reads a -field-defs JSON file mapping field names to {"type", "required"}
+// and merges it over the built-in field definitions
+func loadFieldDefinitions(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read field definitions: %w", err)
+	}
+
+	var defs map[string]mbs.FieldDefinition
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&defs); err != nil {
+		return fmt.Errorf("failed to parse field definitions %s: %w", path, err)
+	}
+	if err := mbs.OverrideFieldDefinitions(defs); err != nil {
+		return fmt.Errorf("invalid field definitions %s: %w", path, err)
+	}
+	log.Printf("Loaded %d field definitions from %s", len(defs), path)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"mbsop/mbs"
+)
+
+// listFlag is a repeatable flag whose values may also be comma-separated
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// matchesAny reports whether a typed field value equals one of the wanted values, ignoring case
+func matchesAny(value interface{}, wanted []string) bool {
+	if value == nil {
+		return false
+	}
+	s := fmt.Sprint(value)
+	for _, w := range wanted {
+		if strings.EqualFold(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reasons recorded in the validation summary for items dropped by a filter
+const (
+	filteredCategory = "FILTERED_CATEGORY"
+	filteredGroup    = "FILTERED_GROUP"
+)
+
+// itemFilter returns a filter giving the reason an item's Category or Group does not match the
+// -filter-category or -filter-group values, or "" to keep it. It returns nil when neither is set.
+func itemFilter(config Config) func(mbs.Item) string {
+	if len(config.filterCategory) == 0 && len(config.filterGroup) == 0 {
+		return nil
+	}
+	return func(item mbs.Item) string {
+		if len(config.filterCategory) > 0 && !matchesAny(item["Category"], config.filterCategory) {
+			return filteredCategory
+		}
+		if len(config.filterGroup) > 0 && !matchesAny(item["Group"], config.filterGroup) {
+			return filteredGroup
+		}
+		return ""
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"mbsop/mbs"
+)
+
+func TestItemFilterReasons(t *testing.T) {
+	config := Config{filterCategory: listFlag{"1"}, filterGroup: listFlag{"A1"}}
+	filter := itemFilter(config)
+
+	tests := []struct {
+		item mbs.Item
+		want string
+	}{
+		{mbs.Item{"ItemNum": "23", "Category": "1", "Group": "A1"}, ""},
+		{mbs.Item{"ItemNum": "3", "Category": "1", "Group": "a1"}, ""},
+		{mbs.Item{"ItemNum": "2501", "Category": "2", "Group": "A1"}, filteredCategory},
+		{mbs.Item{"ItemNum": "104", "Category": "1", "Group": "A3"}, filteredGroup},
+		{mbs.Item{"ItemNum": "55", "Group": "A1"}, filteredCategory},
+		{mbs.Item{"ItemNum": "56", "Category": "1", "Group": nil}, filteredGroup},
+	}
+	for _, tt := range tests {
+		if got := filter(tt.item); got != tt.want {
+			t.Errorf("filter(item %v) = %q, want %q", tt.item["ItemNum"], got, tt.want)
+		}
+	}
+}
+
+func TestItemFilterUnset(t *testing.T) {
+	if filter := itemFilter(Config{}); filter != nil {
+		t.Errorf("itemFilter without -filter-category or -filter-group should be nil")
+	}
+}
+
+func TestFilterReasonsInSummary(t *testing.T) {
+	dataset := convertFixture(t, `<Data><ItemNum>3</ItemNum><Description>Item 3</Description><Category>1</Category><Group>A1</Group></Data>
+<Data><ItemNum>23</ItemNum><Description>Item 23</Description><Category>1</Category><Group>A1</Group></Data>
+<Data><ItemNum>104</ItemNum><Description>Item 104</Description><Category>1</Category><Group>A3</Group></Data>
+<Data><ItemNum>2501</ItemNum><Description>Item 2501</Description><Category>2</Category><Group>A1</Group></Data>`)
+
+	config := Config{filterCategory: listFlag{"1"}, filterGroup: listFlag{"A1"}}
+	if kept := dataset.FilterWithReason(itemFilter(config)); kept != 2 {
+		t.Errorf("FilterWithReason kept %d items, want 2", kept)
+	}
+	summary := dataset.Summary()
+	want := map[string]int{filteredCategory: 1, filteredGroup: 1}
+	if !reflect.DeepEqual(summary.FilterReasons, want) {
+		t.Errorf("FilterReasons = %v, want %v", summary.FilterReasons, want)
+	}
+	if summary.FilteredItems() != 2 || summary.ValidItems != 4 {
+		t.Errorf("got %d filtered of %d valid items, want 2 of 4", summary.FilteredItems(), summary.ValidItems)
+	}
+}
+
+func TestItemFilterKeepsMatchingItems(t *testing.T) {
+	dataset := convertFixture(t, `<Data><ItemNum>3</ItemNum><Description>Item 3</Description><Category>1</Category><Group>A1</Group></Data>
+<Data><ItemNum>2501</ItemNum><Description>Item 2501</Description><Category>2</Category><Group>A1</Group></Data>
+<Data><ItemNum>104</ItemNum><Description>Item 104</Description><Category>1</Category><Group>A3</Group></Data>
+<Data><ItemNum>73</ItemNum><Description>Item 73</Description><Category>6</Category><Group>P1</Group></Data>`)
+
+	config := Config{filterCategory: listFlag{"1", "6"}}
+	dataset.FilterWithReason(itemFilter(config))
+
+	var kept []string
+	for _, item := range dataset.Items() {
+		kept = append(kept, item["ItemNum"].(string))
+	}
+	if want := []string{"3", "104", "73"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept items %v, want %v", kept, want)
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	var l listFlag
+	l.Set("1, 2,,")
+	l.Set("P1")
+	if want := (listFlag{"1", "2", "P1"}); !reflect.DeepEqual(l, want) {
+		t.Errorf("listFlag = %v, want %v", l, want)
+	}
+}
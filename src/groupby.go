@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mbsop/mbs"
+)
+
+// GroupStats holds the item count and ScheduleFee totals for one group value
+type GroupStats struct {
+	Group              string  `json:"group"`
+	ItemCount          int     `json:"item_count"`
+	TotalScheduleFee   float64 `json:"total_schedule_fee"`
+	AverageScheduleFee float64 `json:"average_schedule_fee"`
+}
+
+// GroupReport is the aggregate rollup written by -group-by
+type GroupReport struct {
+	MBSDate string       `json:"mbs_date"`
+	GroupBy string       `json:"group_by"`
+	Groups  []GroupStats `json:"groups"`
+}
+
+// aggregateItems groups items by the value of field and totals their ScheduleFee
+func aggregateItems(items []map[string]interface{}, field string) []GroupStats {
+	stats := make(map[string]*GroupStats)
+	for _, item := range items {
+		group := ""
+		if value, exists := item[field]; exists && value != nil {
+			group = fmt.Sprint(value)
+		}
+		if stats[group] == nil {
+			stats[group] = &GroupStats{Group: group}
+		}
+		stats[group].ItemCount++
+		if fee, ok := toFloat(item["ScheduleFee"]); ok {
+			stats[group].TotalScheduleFee += fee
+		}
+	}
+
+	groups := make([]GroupStats, 0, len(stats))
+	for _, s := range stats {
+		s.AverageScheduleFee = s.TotalScheduleFee / float64(s.ItemCount)
+		groups = append(groups, *s)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return lessItemNum(groups[i].Group, groups[j].Group)
+	})
+	return groups
+}
+
+// checkGroupField rejects a -group-by value that is not a defined item field. The field is part
+// of the report file names, so this also keeps path separators out of them.
+func checkGroupField(field string) error {
+	if _, ok := mbs.LookupFieldType(field); !ok || strings.ContainsAny(field, `/\`) {
+		return fmt.Errorf("unknown field %q (valid fields: %s)", field, strings.Join(mbs.DefinedFields(), ", "))
+	}
+	return nil
+}
+
+// writeGroupReport aggregates the converted items by field and writes
+// mbs_<date>_by_<field>.json and .csv alongside the main output
+func writeGroupReport(mbsDate string, items []map[string]interface{}, field string) error {
+	if err := checkGroupField(field); err != nil {
+		return err
+	}
+	report := GroupReport{
+		MBSDate: mbsDate,
+		GroupBy: field,
+		Groups:  aggregateItems(items, field),
+	}
+	base := filepath.Join(downloadPath, fmt.Sprintf("mbs_%s_by_%s", mbsDate, field))
+
+	var jsonOut bytes.Buffer
+	encoder := json.NewEncoder(&jsonOut)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to format group report: %w", err)
+	}
+	if err := writeFileAtomic(base+".json", jsonOut.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to save group report: %w", err)
+	}
+
+	var csvOut bytes.Buffer
+	writer := csv.NewWriter(&csvOut)
+	writer.Write([]string{field, "ItemCount", "TotalScheduleFee", "AverageScheduleFee"})
+	for _, group := range report.Groups {
+		writer.Write([]string{
+			group.Group,
+			strconv.Itoa(group.ItemCount),
+			strconv.FormatFloat(group.TotalScheduleFee, 'f', 2, 64),
+			strconv.FormatFloat(group.AverageScheduleFee, 'f', 2, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to format group report CSV: %w", err)
+	}
+	if err := writeFileAtomic(base+".csv", csvOut.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to save group report CSV: %w", err)
+	}
+
+	log.Printf("Saved %d %s groups to %s.json and %s.csv", len(report.Groups), field, base, base)
+	return nil
+}
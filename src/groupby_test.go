@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateItems(t *testing.T) {
+	items := []map[string]interface{}{
+		{"ItemNum": "3", "Category": "1", "ScheduleFee": 19.6},
+		{"ItemNum": "23", "Category": "1", "ScheduleFee": 42.85},
+		{"ItemNum": "104", "Category": "1", "ScheduleFee": 100.0},
+		{"ItemNum": "55", "Category": "10", "ScheduleFee": 10.0},
+		{"ItemNum": "56", "Category": "2"}, // No fee counts towards the items but not the total
+		{"ItemNum": "57", "ScheduleFee": 5.0},
+	}
+
+	want := []GroupStats{
+		{Group: "", ItemCount: 1, TotalScheduleFee: 5, AverageScheduleFee: 5},
+		{Group: "1", ItemCount: 3, TotalScheduleFee: 162.45, AverageScheduleFee: 54.15},
+		{Group: "2", ItemCount: 1, TotalScheduleFee: 0, AverageScheduleFee: 0},
+		{Group: "10", ItemCount: 1, TotalScheduleFee: 10, AverageScheduleFee: 10},
+	}
+	got := aggregateItems(items, "Category")
+	if len(got) != len(want) {
+		t.Fatalf("aggregateItems returned %d groups, want %d: %+v", len(got), len(want), got)
+	}
+	cents := func(amount float64) float64 { return math.Round(amount * 100) }
+	for i := range want {
+		// Compare fees in cents to avoid floating point noise
+		g, w := got[i], want[i]
+		if g.Group != w.Group || g.ItemCount != w.ItemCount ||
+			cents(g.TotalScheduleFee) != cents(w.TotalScheduleFee) ||
+			cents(g.AverageScheduleFee) != cents(w.AverageScheduleFee) {
+			t.Errorf("group %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestAggregateItemsCents(t *testing.T) {
+	items := []map[string]interface{}{
+		{"Group": "T1", "ScheduleFee": int64(1960)},
+		{"Group": "T1", "ScheduleFee": int64(4285)},
+	}
+	want := []GroupStats{{Group: "T1", ItemCount: 2, TotalScheduleFee: 6245, AverageScheduleFee: 3122.5}}
+	if got := aggregateItems(items, "Group"); !reflect.DeepEqual(got, want) {
+		t.Errorf("aggregateItems = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteGroupReport(t *testing.T) {
+	downloadPath = t.TempDir()
+	items := []map[string]interface{}{
+		{"ItemNum": "3", "Category": "1", "ScheduleFee": 19.6},
+		{"ItemNum": "55", "Category": "10", "ScheduleFee": 10.0},
+	}
+	if err := writeGroupReport("20250701", items, "Category"); err != nil {
+		t.Fatalf("writeGroupReport: %v", err)
+	}
+	for _, name := range []string{"mbs_20250701_by_Category.json", "mbs_20250701_by_Category.csv"} {
+		if _, err := os.Stat(filepath.Join(downloadPath, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+}
+
+func TestWriteGroupReportRejectsUnknownField(t *testing.T) {
+	dir := t.TempDir()
+	downloadPath = filepath.Join(dir, "downloads")
+	if err := os.Mkdir(downloadPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"NoSuchField", "../../escape", `..\escape`, ""} {
+		if err := writeGroupReport("20250701", nil, field); err == nil {
+			t.Errorf("writeGroupReport accepted -group-by %q", field)
+		}
+	}
+	for _, d := range []string{dir, downloadPath} {
+		entries, _ := os.ReadDir(d)
+		for _, entry := range entries {
+			if entry.Name() != "downloads" {
+				t.Errorf("rejected -group-by values wrote %s", filepath.Join(d, entry.Name()))
+			}
+		}
+	}
+}
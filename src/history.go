@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// archivedVersionRegex matches the JSON files written by downloadAndConvertXML, including
+// those compressed by -gzip-output
+var archivedVersionRegex = regexp.MustCompile(`^mbs_(\d{8})\.json(\.gz)?$`)
+
+// ArchivedVersion is a previously downloaded MBS version in the downloads directory
+type ArchivedVersion struct {
+	Date string
+	Path string
+}
+
+// listArchivedVersions returns the downloaded versions sorted from oldest to newest.
+// When a version has both a plain and a gzipped file, the plain file is used.
+func listArchivedVersions() ([]ArchivedVersion, error) {
+	files, err := os.ReadDir(downloadPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read downloads directory: %w", err)
+	}
+
+	// ReadDir sorts by name, so mbs_<date>.json is seen before mbs_<date>.json.gz
+	seen := make(map[string]bool)
+	var versions []ArchivedVersion
+	for _, file := range files {
+		matches := archivedVersionRegex.FindStringSubmatch(file.Name())
+		if matches == nil || seen[matches[1]] {
+			continue
+		}
+		seen[matches[1]] = true
+		versions = append(versions, ArchivedVersion{
+			Date: matches[1],
+			Path: filepath.Join(downloadPath, file.Name()),
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Date < versions[j].Date
+	})
+	return versions, nil
+}
+
+// loadItems reads the MBS_Items array from a converted JSON file, decompressing .gz files
+func loadItems(path string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if strings.HasSuffix(path, ".gz") {
+		if data, err = gunzip(data); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+	}
+
+	var parsed struct {
+		Items []map[string]interface{} `json:"MBS_Items"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return parsed.Items, nil
+}
+
+// lessItemNum orders item numbers numerically where possible, falling back to string order
+func lessItemNum(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
+
+// formatCSVNumber renders a numeric JSON value without trailing zeros
+func formatCSVNumber(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeFeeHistoryCSV joins every archived version by ItemNum and writes one row per item
+// with a ScheduleFee_<date> column for each version
+func writeFeeHistoryCSV(outputPath string) error {
+	versions, err := listArchivedVersions()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no archived mbs_*.json files found in %s", downloadPath)
+	}
+
+	// fees[itemNum][date] = ScheduleFee
+	fees := make(map[string]map[string]interface{})
+	for _, version := range versions {
+		items, err := loadItems(version.Path)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			itemNum, ok := item["ItemNum"].(string)
+			if !ok || itemNum == "" {
+				continue
+			}
+			if fees[itemNum] == nil {
+				fees[itemNum] = make(map[string]interface{})
+			}
+			fees[itemNum][version.Date] = item["ScheduleFee"]
+		}
+		log.Printf("Loaded %d items from version %s", len(items), version.Date)
+	}
+
+	var itemNums []string
+	for itemNum := range fees {
+		itemNums = append(itemNums, itemNum)
+	}
+	sort.Slice(itemNums, func(i, j int) bool {
+		return lessItemNum(itemNums[i], itemNums[j])
+	})
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+	header := []string{"ItemNum"}
+	for _, version := range versions {
+		header = append(header, "ScheduleFee_"+version.Date)
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, itemNum := range itemNums {
+		row := []string{itemNum}
+		for _, version := range versions {
+			row = append(row, formatCSVNumber(fees[itemNum][version.Date]))
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	log.Printf("Wrote fee history for %d items across %d versions to %s", len(itemNums), len(versions), outputPath)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"mbsop/mbs"
+)
+
+// inputDate returns the MBS date for an -input file: the -date flag if set, otherwise the date
+// embedded in a file name like MBS-XML-20250101.XML
+func inputDate(config Config) (string, error) {
+	if config.date != "" {
+		if !mbsDateRegex.MatchString(config.date) {
+			return "", fmt.Errorf("invalid -date %q: must be YYYYMMDD", config.date)
+		}
+		return config.date, nil
+	}
+	mbsDate, err := mbs.XMLLinkDate(filepath.Base(config.input))
+	if err != nil {
+		return "", fmt.Errorf("cannot determine the MBS date from %s, use -date YYYYMMDD", config.input)
+	}
+	return mbsDate, nil
+}
+
+// convertLocalXML runs a local XML file through the conversion, validation and sink pipeline
+// without scraping or downloading anything. The file is always converted, replacing any
+// existing output for the same date.
+func convertLocalXML(ctx context.Context, config Config, requiredSinks map[string]bool, mbsDate string) error {
+	record := RunRecord{Action: "failed", MBSDate: mbsDate}
+	fail := func(err error) error {
+		record.Errors = append(record.Errors, err.Error())
+		writeRunLog(config, record)
+		return err
+	}
+
+	log.Printf("Reading XML from: %s", config.input)
+	xmlData, err := os.ReadFile(config.input)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read input XML: %w", err))
+	}
+	if err := checkXMLContent(xmlData, config.minXMLBytes); err != nil {
+		return fail(withExitCode(exitParse, err))
+	}
+
+	result, err := convertXML(xmlData, mbsDate, config.input, config)
+	if err != nil {
+		return fail(fmt.Errorf("failed to process XML: %w", err))
+	}
+	if err := completeCycle(ctx, config, requiredSinks, result, record); err != nil {
+		return err
+	}
+	fmt.Println("Successfully converted MBS data!")
+	return nil
+}
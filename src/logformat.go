@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logLevelPrefixes maps the message prefixes used throughout the code to log levels
+var logLevelPrefixes = []struct {
+	prefix string
+	level  string
+}{
+	{"Debug: ", "debug"},
+	{"Warning: ", "warn"},
+	{"Error: ", "error"},
+}
+
+// Log verbosity levels set by -quiet and -verbose
+const (
+	verbosityQuiet   = iota // Only warnings and errors
+	verbosityNormal         // Also informational messages
+	verbosityVerbose        // Also debug messages such as every link examined while scraping
+)
+
+// verbosity is the current log verbosity, set by setupLogging
+var verbosity = verbosityNormal
+
+// debugf logs a message that is only shown with -verbose
+func debugf(format string, v ...interface{}) {
+	if verbosity >= verbosityVerbose {
+		log.Output(2, "Debug: "+fmt.Sprintf(format, v...))
+	}
+}
+
+// parseLogLine splits a line from the standard logger into its log.Lshortfile source
+// (if any), level and message with the level prefix removed
+func parseLogLine(line string) (source, level, message string) {
+	level, message = "info", line
+
+	// log.Lshortfile prefixes the line with "file.go:123: ", after any timestamp
+	if i := strings.Index(message, ".go:"); i >= 0 {
+		if j := strings.Index(message[i:], ": "); j >= 0 {
+			source = message[:i+j]
+			if k := strings.LastIndex(source, " "); k >= 0 {
+				source = source[k+1:]
+			}
+			message = message[i+j+2:]
+		}
+	}
+	for _, l := range logLevelPrefixes {
+		if strings.HasPrefix(message, l.prefix) {
+			return source, l.level, strings.TrimPrefix(message, l.prefix)
+		}
+	}
+	return source, level, message
+}
+
+// quietLogWriter passes on only warning and error lines
+type quietLogWriter struct {
+	out io.Writer
+}
+
+func (w quietLogWriter) Write(p []byte) (int, error) {
+	if _, level, _ := parseLogLine(strings.TrimSuffix(string(p), "\n")); level == "info" || level == "debug" {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// jsonLogWriter receives lines from the standard logger (with only log.Lshortfile set)
+// and writes each one as a JSON object
+type jsonLogWriter struct {
+	out io.Writer
+}
+
+// Write converts a single log line to JSON with level, timestamp, message and source
+func (w jsonLogWriter) Write(p []byte) (int, error) {
+	source, level, message := parseLogLine(strings.TrimSuffix(string(p), "\n"))
+	if err := writeJSONLog(w.out, time.Now(), source, level, message, nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeJSONLog writes one -log-format json entry with any extra fields, such as url or mbs_date
+func writeJSONLog(out io.Writer, t time.Time, source, level, message string, fields map[string]interface{}) error {
+	entry := map[string]interface{}{}
+	for key, value := range fields {
+		entry[key] = value
+	}
+	entry["timestamp"] = t.UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["message"] = message
+	if source != "" {
+		entry["source"] = source
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+// logHandler writes log/slog records in the same format as the standard logger. Messages logged
+// through slog are constant and carry their details, such as url, status, mbs_date and item_count,
+// as attributes: fields in -log-format json, and key=value pairs after the message in text logs.
+type logHandler struct {
+	out   io.Writer // Destination before any JSON or -quiet wrapping
+	json  bool
+	attrs []slog.Attr
+}
+
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	switch {
+	case level >= slog.LevelWarn:
+		return true
+	case level >= slog.LevelInfo:
+		return verbosity >= verbosityNormal
+	default:
+		return verbosity >= verbosityVerbose
+	}
+}
+
+func (h *logHandler) Handle(_ context.Context, r slog.Record) error {
+	var source string
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		source = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	}
+
+	level, prefix := "info", ""
+	switch {
+	case r.Level >= slog.LevelError:
+		level, prefix = "error", "Error: "
+	case r.Level >= slog.LevelWarn:
+		level, prefix = "warn", "Warning: "
+	case r.Level < slog.LevelInfo:
+		level, prefix = "debug", "Debug: "
+	}
+
+	if !h.json {
+		var line strings.Builder
+		fmt.Fprintf(&line, "%s %s: %s%s", r.Time.Format("2006/01/02 15:04:05"), source, prefix, r.Message)
+		add := func(a slog.Attr) bool {
+			fmt.Fprintf(&line, " %s=%s", a.Key, textLogValue(a.Value))
+			return true
+		}
+		for _, a := range h.attrs {
+			add(a)
+		}
+		r.Attrs(add)
+		line.WriteByte('\n')
+		_, err := io.WriteString(h.out, line.String())
+		return err
+	}
+
+	fields := make(map[string]interface{})
+	add := func(a slog.Attr) bool {
+		fields[a.Key] = jsonLogValue(a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+	return writeJSONLog(h.out, r.Time, source, level, r.Message, fields)
+}
+
+// textLogValue formats an attribute value for a text log line, quoting it if it is empty or
+// contains spaces, quotes or an equals sign
+func textLogValue(v slog.Value) string {
+	text := v.Resolve().String()
+	if text == "" || strings.ContainsAny(text, " \t\r\n\"=") {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// jsonLogValue converts an attribute value for encoding, writing errors as their message and
+// durations in Go syntax (e.g. 1.2s) rather than as an empty object and nanoseconds
+func jsonLogValue(v slog.Value) interface{} {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+	}
+	return v.Any()
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logHandler{out: h.out, json: h.json, attrs: append(append([]slog.Attr{}, h.attrs...), attrs...)}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return h // Groups are not used
+}
+
+// setupLogging configures the standard logger and log/slog for the -log-format value and verbosity
+func setupLogging(format string, level int, out io.Writer) {
+	verbosity = level
+
+	// slog.SetDefault redirects the standard logger, so it is set up first and then replaced below
+	slog.SetDefault(slog.New(&logHandler{out: out, json: format == "json"}))
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if format == "json" {
+		log.SetFlags(log.Lshortfile)
+		out = jsonLogWriter{out: out}
+	}
+	// Filter on the raw line, before it is converted to JSON
+	if level == verbosityQuiet {
+		out = quietLogWriter{out: out}
+	}
+	log.SetOutput(out)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs sets up logging into a buffer for the duration of a test
+func captureLogs(t *testing.T, format string, level int) *bytes.Buffer {
+	var buf bytes.Buffer
+	setupLogging(format, level, &buf)
+	t.Cleanup(func() {
+		setupLogging("text", verbosityNormal, io.Discard)
+	})
+	return &buf
+}
+
+// jsonLogEntries decodes each line of -log-format json output
+func jsonLogEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONLogFields(t *testing.T) {
+	buf := captureLogs(t, "json", verbosityNormal)
+
+	slog.Info("Converted MBS version", "mbs_date", "20250101", "item_count", 5958)
+	slog.Warn("Request failed, retrying", "url", "https://example.com/mbs.xml", "status", 503,
+		"error", errors.New("HTTP request failed with status: 503"), "delay", 1200*time.Millisecond)
+	// Fields are only taken from attributes, never guessed from the message
+	log.Printf("Warning: Skipping MBS version 20250201 with 12 valid items from https://example.com")
+
+	entries := jsonLogEntries(t, buf)
+	if len(entries) != 3 {
+		t.Fatalf("got %d log entries, want 3", len(entries))
+	}
+	if e := entries[0]; e["level"] != "info" || e["mbs_date"] != "20250101" || e["item_count"] != 5958.0 ||
+		!strings.HasPrefix(e["source"].(string), "logformat_test.go:") {
+		t.Errorf("converted entry = %v", e)
+	}
+	if e := entries[1]; e["level"] != "warn" || e["message"] != "Request failed, retrying" || e["url"] != "https://example.com/mbs.xml" ||
+		e["status"] != 503.0 || e["error"] != "HTTP request failed with status: 503" || e["delay"] != "1.2s" {
+		t.Errorf("retry entry = %v", e)
+	}
+	e := entries[2]
+	if e["level"] != "warn" || e["message"] != "Skipping MBS version 20250201 with 12 valid items from https://example.com" {
+		t.Errorf("standard logger entry = %v", e)
+	}
+	for _, field := range []string{"url", "status", "mbs_date", "item_count"} {
+		if _, exists := e[field]; exists {
+			t.Errorf("standard logger entry has %s extracted from the message: %v", field, e)
+		}
+	}
+}
+
+func TestTextLogFields(t *testing.T) {
+	buf := captureLogs(t, "text", verbosityNormal)
+
+	slog.Warn("Request failed, retrying", "url", "https://example.com", "status", 503,
+		"error", errors.New("HTTP request failed with status: 503"), "delay", time.Second)
+	line := buf.String()
+	want := `: Warning: Request failed, retrying url=https://example.com status=503 error="HTTP request failed with status: 503" delay=1s` + "\n"
+	if !strings.Contains(line, " logformat_test.go:") || !strings.HasSuffix(line, want) {
+		t.Errorf("text log line = %q, want it to end with %q", line, want)
+	}
+}
+
+func TestQuietLogLevels(t *testing.T) {
+	buf := captureLogs(t, "json", verbosityQuiet)
+
+	slog.Info("Fetching page", "url", "https://example.com")
+	log.Printf("Found latest link")
+	slog.Error("Download failed", "url", "https://example.com")
+
+	entries := jsonLogEntries(t, buf)
+	if len(entries) != 1 || entries[0]["level"] != "error" {
+		t.Errorf("-quiet entries = %v, want only the error", entries)
+	}
+}
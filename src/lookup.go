@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"mbsop/mbs"
+)
+
+// latestXMLLink follows the downloads page to the latest version page and returns its XML link
+func latestXMLLink(ctx context.Context, config Config) (string, error) {
+	doc, err := fetchPage(ctx, config.baseURL, config)
+	if err != nil {
+		return "", withExitCode(exitNetwork, fmt.Errorf("failed to fetch downloads page: %w", err))
+	}
+	latestLink := mbs.FindLatestLink(doc, config.baseURL, mbs.Hooks{})
+	if latestLink == "" {
+		return "", withExitCode(exitParse, fmt.Errorf("could not find latest MBS link"))
+	}
+
+	downloadDoc, err := fetchPage(ctx, latestLink, config)
+	if err != nil {
+		return "", withExitCode(exitNetwork, fmt.Errorf("failed to fetch download page: %w", err))
+	}
+	xmlLink := mbs.FindXMLLink(downloadDoc, config.baseURL)
+	if xmlLink == "" {
+		return "", withExitCode(exitParse, fmt.Errorf("could not find XML download link"))
+	}
+	return xmlLink, nil
+}
+
+// latestItems returns the items of the latest version, read from its archived JSON file when it
+// was already downloaded and otherwise downloaded and converted in memory without writing anything
+func latestItems(ctx context.Context, config Config) ([]map[string]interface{}, error) {
+	xmlLink, err := latestXMLLink(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	mbsDate, err := mbs.XMLLinkDate(xmlLink)
+	if err != nil {
+		return nil, withExitCode(exitParse, err)
+	}
+
+	if !config.force {
+		versions, err := listArchivedVersions()
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if version.Date == mbsDate {
+				slog.Info("Reading archived MBS version", "mbs_date", mbsDate, "path", version.Path)
+				return loadItems(version.Path)
+			}
+		}
+	}
+
+	xmlData, err := downloadXML(ctx, xmlLink, config)
+	if err != nil {
+		return nil, err
+	}
+	dataset, err := mbs.Convert(bytes.NewReader(xmlData))
+	if err != nil {
+		return nil, withExitCode(exitParse, err)
+	}
+	if err := mbs.ValidateWithOptions(dataset, conversionOptions(config)); err != nil {
+		return nil, withExitCode(exitValidation, fmt.Errorf("JSON validation failed: %w", err))
+	}
+	return dataset.Items(), nil
+}
+
+// printItem writes the latest version's item with the given ItemNum to w as indented JSON
+func printItem(ctx context.Context, config Config, itemNum string, w io.Writer) error {
+	items, err := latestItems(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if item["ItemNum"] == itemNum {
+			data, err := json.MarshalIndent(orderedItem(item), "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to format item: %w", err)
+			}
+			_, err = fmt.Fprintln(w, string(data))
+			return err
+		}
+	}
+	return withExitCode(exitItemNotFound, fmt.Errorf("item %s not found in the latest MBS version", itemNum))
+}
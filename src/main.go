@@ -2,166 +2,222 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/basgys/goxml2json"
-)
 
-const (
-	baseURL      = "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads"
-	downloadPath = "downloads"
+	"mbsop/mbs"
 )
 
-// Config holds the command-line arguments
-type Config struct {
-	execCmd      string
-	webhookURL   string
-	webhookHeaders string // JSON string of key-value pairs for headers
-	force        bool
-	sync         bool
-}
-
-// Field type definitions
-type FieldType int
-
 const (
-	StringType FieldType = iota
-	BooleanType
-	DateType
-	FloatType
+	defaultBaseURL      = "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads"
+	defaultDownloadPath = "downloads"
 )
 
-// FieldInfo stores information about how to process each field
-type FieldInfo struct {
-	fieldType FieldType
-	required  bool
-}
+// downloadPath is the directory all outputs, archives and logs are written to, set by -out-dir
+var downloadPath = defaultDownloadPath
 
-// fieldDefinitions defines the type and requirements for each field
-var fieldDefinitions = map[string]FieldInfo{
-	// Required fields
-	"ItemNum":     {StringType, true},  // Item number (required)
-	"Description": {StringType, true},  // Description (required)
-
-	// Boolean fields (Y/N)
-	"NewItem":          {BooleanType, false},
-	"ItemChange":       {BooleanType, false},
-	"FeeChange":        {BooleanType, false},
-	"BenefitChange":    {BooleanType, false},
-	"AnaesChange":      {BooleanType, false},
-	"EMSNChange":       {BooleanType, false},
-	"DescriptorChange": {BooleanType, false},
-	"Anaes":            {BooleanType, false},
-
-	// Date fields (DD.MM.YYYY)
-	"ItemStartDate":        {DateType, false},
-	"ItemEndDate":          {DateType, false},
-	"FeeStartDate":         {DateType, false},
-	"BenefitStartDate":     {DateType, false},
-	"DescriptionStartDate": {DateType, false},
-	"EMSNStartDate":        {DateType, false},
-	"EMSNEndDate":          {DateType, false},
-	"QFEStartDate":         {DateType, false},
-	"QFEEndDate":           {DateType, false},
-	"DerivedFeeStartDate":  {DateType, false},
-	"EMSNChangeDate":       {DateType, false},
-
-	// Float fields (monetary amounts and percentages)
-	"ScheduleFee":        {FloatType, false},
-	"DerivedFee":         {FloatType, false},
-	"Benefit75":          {FloatType, false},
-	"Benefit85":          {FloatType, false},
-	"Benefit100":         {FloatType, false},
-	"EMSNPercentageCap":  {FloatType, false},
-	"EMSNMaximumCap":     {FloatType, false},
-	"EMSNFixedCapAmount": {FloatType, false},
-	"EMSNCap":            {FloatType, false},
-	"BasicUnits":         {FloatType, false},
-
-	// String fields (everything else defaults to string)
-	"Category":           {StringType, false},
-	"Group":              {StringType, false},
-	"SubGroup":           {StringType, false},
-	"SubHeading":         {StringType, false},
-	"ItemType":           {StringType, false},
-	"SubItemNum":         {StringType, false},
-	"BenefitType":        {StringType, false},
-	"FeeType":            {StringType, false},
-	"ProviderType":       {StringType, false},
-	"EMSNDescription":    {StringType, false},
+// Config holds the command-line arguments
+type Config struct {
+	execCmd            string
+	webhookURL         string
+	webhookHeaders     string // JSON string of key-value pairs for headers
+	force              bool
+	sync               bool
+	moneyAsCents       bool          // Emit monetary fields as integer cents instead of floats
+	traceItem          string        // ItemNum whose field conversions are logged in detail
+	previewHTML        bool          // Print the scraped links and exit before downloading
+	webhookMultipart   bool          // Send the webhook as multipart/form-data with the JSON as a file
+	runLog             bool          // Append each run's outcome to the run log in the downloads directory
+	runLogMaxBytes     int64         // Size at which the run log is rotated
+	runLogKeep         int           // Number of rotated run log files to retain
+	cacheBust          bool          // Ask caches for fresh content on every request
+	historyCSV         string        // Path of the wide ScheduleFee history CSV to build from archived versions
+	dnsServer          string        // Fallback DNS server used when the system resolver fails
+	requireFields      string        // Comma-separated fields required in addition to the field definitions
+	changelog          bool          // Append a summary of each new version to CHANGELOG.md
+	normalizeNumbers   bool          // Accept thousands separators and comma decimals in float fields
+	checkpoint         bool          // Checkpoint converted items so an interrupted conversion can resume
+	checkpointEvery    int           // Number of items between checkpoints
+	groupBy            string        // Field to aggregate item counts and ScheduleFee by
+	requiredSinks      string        // Comma-separated sinks whose failure fails the run
+	strictSchema       bool          // Exit with exitSchemaDrift when fields differ from the field definitions
+	schemaDriftWebhook string        // URL notified when schema drift is detected with -strict-schema
+	format             string        // Output format: json, csv, ndjson or sqlite; json or text for -diff
+	allVersions        bool          // Download every version on the downloads page instead of only the latest
+	since              string        // Only download versions released in or after this month (YYYY-MM); implies -all
+	diff               string        // Compare two versions ("OLD,NEW" as JSON paths or YYYYMMDD dates) and exit
+	baseURL            string        // URL of the MBS downloads page
+	outDir             string        // Directory outputs are written to
+	retries            int           // Maximum attempts for each HTTP fetch
+	minXMLBytes        int64         // Minimum size of a downloaded XML file
+	watch              bool          // Repeat the cycle every interval until interrupted
+	interval           time.Duration // Time between cycles in watch mode
+	webhookRetries     int           // Maximum webhook attempts
+	webhookTimeout     time.Duration // Timeout for each webhook attempt
+	webhookSecret      string        // HMAC-SHA256 key for the X-Signature webhook header
+	keepXML            bool          // Save the downloaded XML alongside the output
+	dryRun             bool          // Report whether a new version is available without downloading
+	omitEmpty          bool          // Omit empty and missing fields from items
+	logFormat          string        // Log output format: text or json
+	statsFile          string        // Path to write the validation stats JSON to
+	maxSkipRatio       float64       // Maximum fraction of items validation may skip
+	printSchema        bool          // Print the item JSON Schema and exit
+	filterCategory     listFlag      // Categories to keep in the output
+	filterGroup        listFlag      // Groups to keep in the output
+	proxy              string        // Proxy URL for all HTTP requests
+	userAgent          string        // User-Agent sent with all HTTP requests
+	quiet              bool          // Only log warnings and errors
+	verbose            bool          // Also log debug messages
+	gzipOutput         bool          // Compress the output file with gzip
+	configFile         string        // YAML or JSON file of flag values, overridden by command-line flags
+	merge              bool          // Combine the archived versions into mbs_combined.json and exit
+	failOnDuplicate    bool          // Fail validation when two items share an ItemNum
+	timeout            time.Duration // Deadline for a run, or each -watch cycle; 0 disables
+	triStateBooleans   bool          // Output empty or unrecognized booleans as null
+	uploadS3           string        // s3://bucket/prefix to upload the output to
+	s3Endpoint         string        // Endpoint of an S3-compatible store for -upload-s3
+	strict             bool          // Skip items with out-of-range values
+	item               string        // ItemNum to print from the latest version
+	concurrency        int           // Number of versions downloaded and converted in parallel with -all
+	metricsAddr        string        // Address to serve Prometheus metrics on in watch mode
+	fieldDefs          string        // JSON file of field definitions merged over the built-in ones
+	verifyManifest     bool          // Check the downloaded files against manifest.json and exit
+	input              string        // Local XML file to convert instead of downloading
+	date               string        // MBS date of the -input file
+	webhookDelta       bool          // Send the webhook only the changes since the previous version
+	notifyNoChange     bool          // Notify the webhook when no new version is found
+	serveAddr          string        // Address to serve POST /convert on
+	sqliteDB           string        // Long-lived SQLite database each new version is loaded into
+	s3Gzip             bool          // Gzip objects uploaded by -upload-s3, leaving the local files plain
 }
 
-// convertValue converts a string value to its appropriate type based on the field definition
-func convertValue(field string, value string) interface{} {
-	// Get field info, default to string type if not defined
-	fieldInfo, exists := fieldDefinitions[field]
-	if !exists {
-		return value
-	}
-
-	// Handle empty values
-	if value == "" {
-		switch fieldInfo.fieldType {
-		case BooleanType:
-			return false
-		case DateType:
-			return nil
-		case FloatType:
-			return 0.0
+// splitCommandLine splits a command into words the way a POSIX shell would, without expanding
+// anything. Whitespace separates words, single quotes keep their contents literally, double
+// quotes allow backslash escapes of $, `, " and \, and a backslash outside quotes escapes
+// the next character.
+func splitCommandLine(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			// Inside double quotes a backslash only escapes characters the shell treats specially
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
 		default:
-			return ""
+			word.WriteRune(r)
+			inWord = true
 		}
 	}
 
-	switch fieldInfo.fieldType {
-	case BooleanType:
-		return strings.ToUpper(value) == "Y"
-	
-	case DateType:
-		// Parse date in DD.MM.YYYY format
-		if t, err := time.Parse("02.01.2006", value); err == nil {
-			return t.Format("2006-01-02") // Convert to ISO 8601 format
-		}
-		return nil
-
-	case FloatType:
-		// Try to parse as float
-		if f, err := strconv.ParseFloat(value, 64); err == nil {
-			return f
-		}
-		return 0.0
-
-	default:
-		return value
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
 	}
+	return words, nil
 }
 
-// executeCommand runs the specified command with the JSON file path
-func executeCommand(cmdTemplate string, jsonPath string, sync bool) error {
-	// Replace {file} with the actual path
-	cmd := strings.ReplaceAll(cmdTemplate, "{file}", jsonPath)
-	
-	// Split the command into program and arguments
-	parts := strings.Fields(cmd)
+// expandCommand replaces the -exec placeholders with the values for a converted version.
+// {xml} expands to an empty string unless -keep-xml saved the source XML.
+func expandCommand(cmdTemplate string, mbsDate string, result ConversionResult) string {
+	return strings.NewReplacer(
+		"{file}", result.OutputPath,
+		"{json}", result.OutputPath,
+		"{date}", mbsDate,
+		"{count}", strconv.Itoa(len(result.Items)),
+		"{xml}", result.XMLPath,
+	).Replace(cmdTemplate)
+}
+
+// executeCommand runs the specified command with its placeholders expanded. The MBS date, file path
+// and item count are also passed in the MBS_DATE, MBS_JSON_PATH and MBS_ITEM_COUNT environment
+// variables, on top of the inherited environment.
+func executeCommand(ctx context.Context, cmdTemplate string, mbsDate string, result ConversionResult, sync bool) error {
+	cmd := expandCommand(cmdTemplate, mbsDate, result)
+
+	// Split the command into program and arguments before expanding placeholders,
+	// so a path containing spaces stays a single argument
+	parts, err := splitCommandLine(cmdTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid command: %w", err)
+	}
 	if len(parts) == 0 {
 		return fmt.Errorf("empty command")
 	}
+	for i, part := range parts {
+		parts[i] = expandCommand(part, mbsDate, result)
+	}
 
-	// Create command
-	command := exec.Command(parts[0], parts[1:]...)
+	// Create command. A synchronous command is killed when ctx is done, such as at the -timeout
+	// deadline; a background command is left running, since it is expected to outlive the run.
+	commandCtx := context.Background()
+	if sync {
+		commandCtx = ctx
+	}
+	command := exec.CommandContext(commandCtx, parts[0], parts[1:]...)
+	command.Env = append(os.Environ(),
+		"MBS_DATE="+mbsDate,
+		"MBS_JSON_PATH="+result.OutputPath,
+		"MBS_ITEM_COUNT="+strconv.Itoa(len(result.Items)),
+	)
 	
 	if sync {
 		// Run synchronously
@@ -193,60 +249,195 @@ func executeCommand(cmdTemplate string, jsonPath string, sync bool) error {
 	return nil
 }
 
-// sendWebhook sends the JSON file to the specified webhook URL
-func sendWebhook(webhookURL string, webhookHeaders string, jsonPath string) error {
-	// Read the JSON file
-	jsonData, err := os.ReadFile(jsonPath)
+// buildMultipartBody wraps the output data in a multipart/form-data body with the file
+// attached as "file" and summary fields alongside it. It returns the body and its content type.
+func buildMultipartBody(fileData []byte, filename string, mbsDate string, itemCount int) (*bytes.Buffer, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	// Include the item count so receivers can sanity check the upload without parsing it
+	fields := map[string]string{
+		"mbs_date":   mbsDate,
+		"item_count": strconv.Itoa(itemCount),
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, "", fmt.Errorf("failed to write form field %s: %w", name, err)
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", filename)
 	if err != nil {
-		return fmt.Errorf("failed to read JSON file: %w", err)
+		return nil, "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(fileData); err != nil {
+		return nil, "", fmt.Errorf("failed to write form file: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to finalize multipart body: %w", err)
 	}
+	return body, writer.FormDataContentType(), nil
+}
 
-	// Create the request
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
+// signPayload returns the "sha256=<hex>" HMAC-SHA256 signature of a webhook body
+func signPayload(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook sends the output file, or with -webhook-delta the changes since the previous version, to the configured webhook URL
+func sendWebhook(ctx context.Context, config Config, result ConversionResult, mbsDate string) error {
+	// Read the output file
+	fileData, err := os.ReadFile(result.OutputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to read output file: %w", err)
 	}
 
-	// Set default Content-Type header
-	req.Header.Set("Content-Type", "application/json")
+	// Build the body, either the raw file (default) or a multipart form
+	body := bytes.NewBuffer(fileData)
+	contentType := outputContentTypes[config.format]
+	gzipped := config.gzipOutput
+	if config.webhookMultipart {
+		body, contentType, err = buildMultipartBody(fileData, filepath.Base(result.OutputPath), mbsDate, len(result.Items))
+		if err != nil {
+			return err
+		}
+		gzipped = false
+	}
+
+	// With -webhook-delta, send only the changes since the previous version when there is one
+	if config.webhookDelta {
+		delta, found, err := buildDeltaPayload(mbsDate, result.Items)
+		if err != nil {
+			return err
+		}
+		if found {
+			body, contentType, gzipped = bytes.NewBuffer(delta), "application/json", false
+		}
+	}
 
-	// Parse and set custom headers if provided
-	if webhookHeaders != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(webhookHeaders), &headers); err != nil {
+	return postWebhook(ctx, config, body.Bytes(), contentType, gzipped)
+}
+
+// postWebhook posts a payload to the configured webhook URL with the custom headers and signature,
+// retrying network errors and 5xx responses
+func postWebhook(ctx context.Context, config Config, payload []byte, contentType string, gzipped bool) error {
+	// Parse custom headers if provided
+	var headers map[string]string
+	if config.webhookHeaders != "" {
+		if err := json.Unmarshal([]byte(config.webhookHeaders), &headers); err != nil {
 			return fmt.Errorf("failed to parse webhook headers: %w", err)
 		}
+	}
+
+	attempts := config.webhookRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+	client := &http.Client{Timeout: config.webhookTimeout, Transport: httpClient.Transport}
+
+	// Send the request, retrying network errors and 5xx responses with backoff
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", config.webhookURL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		// Set default Content-Type header, then any custom headers
+		req.Header.Set("Content-Type", contentType)
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
 		for key, value := range headers {
 			req.Header.Set(key, value)
 		}
+		if config.webhookSecret != "" {
+			req.Header.Set("X-Signature", signPayload(payload, config.webhookSecret))
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			resp.Body.Close()
+			slog.Info("Webhook sent", "url", config.webhookURL, "attempt", attempt, "attempts", attempts)
+			return nil
+		}
+
+		retry := shouldRetry(resp, err)
+		if err != nil {
+			err = fmt.Errorf("failed to send webhook: %w", err)
+		} else {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("webhook failed with status %d: %s", resp.StatusCode, string(respBody))
+		}
+		if !retry || attempt == attempts || ctx.Err() != nil {
+			return fmt.Errorf("%w (attempt %d/%d)", err, attempt, attempts)
+		}
+
+		delay := retryDelay(attempt)
+		slog.Warn("Webhook attempt failed, retrying", "url", config.webhookURL, "attempt", attempt, "attempts", attempts, "error", err, "delay", delay.Round(time.Millisecond))
+		if sleepContext(ctx, delay) != nil {
+			return fmt.Errorf("%w (attempt %d/%d)", err, attempt, attempts)
+		}
+	}
+}
+
+// postJSON sends a small JSON payload to a URL, expecting a 2xx response
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
 	}
 
-	// Send the request
-	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: httpClient.Transport}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Check response
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("webhook failed with status %d: %s", resp.StatusCode, string(body))
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
 	}
+	return nil
+}
 
-	log.Printf("Webhook sent successfully to %s", webhookURL)
+// sendSchemaDriftWebhook notifies monitoring of the fields that differ from the field definitions
+func sendSchemaDriftWebhook(ctx context.Context, url string, mbsDate string, summary mbs.ValidationSummary) error {
+	payload := map[string]interface{}{
+		"status":         "schema_drift",
+		"mbs_date":       mbsDate,
+		"new_fields":     summary.UnknownFields,
+		"missing_fields": summary.MissingFields,
+	}
+	if err := postJSON(ctx, url, payload); err != nil {
+		return err
+	}
+	slog.Info("Schema drift webhook sent", "url", url, "mbs_date", mbsDate)
 	return nil
 }
 
-// extractDateFromXMLLink extracts the date from an MBS XML filename
-func extractDateFromXMLLink(xmlLink string) (string, error) {
-	re := regexp.MustCompile(`MBS-XML-(\d{8})\.XML`)
-	matches := re.FindStringSubmatch(xmlLink)
-	if len(matches) < 2 {
-		return "", fmt.Errorf("no date found in XML link: %s", xmlLink)
+// sendNoChangeWebhook tells the webhook that a check ran and found no new version, so monitoring
+// can tell a quiet month from a scraper that stopped running
+func sendNoChangeWebhook(ctx context.Context, config Config, mbsDate string) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"status":     "up_to_date",
+		"mbs_date":   mbsDate,
+		"checked_at": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
 	}
-	return matches[1], nil
+	return postWebhook(ctx, config, payload, "application/json", false)
 }
 
 // hasLatestVersion checks if we already have a JSON file for the given MBS date
@@ -257,8 +448,12 @@ func hasLatestVersion(mbsDate string) (bool, error) {
 		return false, fmt.Errorf("failed to read downloads directory: %w", err)
 	}
 
-	// Look for any file containing the MBS date
+	// Look for any file containing the MBS date. Source XML kept by -keep-xml does not count,
+	// since it is saved before conversion and may belong to a failed run.
 	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".xml") {
+			continue
+		}
 		if strings.Contains(file.Name(), mbsDate) {
 			return true, nil
 		}
@@ -267,358 +462,764 @@ func hasLatestVersion(mbsDate string) (bool, error) {
 	return false, nil
 }
 
-// validateJSON checks if the JSON structure is valid and consistent
-func validateJSON(data map[string]interface{}) error {
-	// Check if MBS_Items exists and is an array
-	items, ok := data["MBS_Items"].([]interface{})
-	if !ok {
-		return fmt.Errorf("MBS_Items is not an array or is missing")
+func main() {
+	if err := run(); err != nil {
+		code := exitCode(err)
+		switch {
+		case errors.Is(err, errNewVersion), errors.Is(err, errUpToDate):
+			// Expected outcomes, already reported
+		case errors.Is(err, errSchemaDrift):
+			log.Printf("Exiting with code %d due to schema drift", code)
+		default:
+			log.Printf("Error: %v", err)
+		}
+		os.Exit(code)
 	}
+}
 
-	if len(items) == 0 {
-		return fmt.Errorf("MBS_Items array is empty")
+// run parses the flags and performs the requested operation. The returned error determines
+// the exit code; see exitCode.
+func run() error {
+	// Parse command line flags
+	config := Config{}
+	flag.StringVar(&config.execCmd, "exec", "", "Command to execute when a new file is found. Placeholders: {file} or {json} (output path), {date} (MBS date), {count} (item count), {xml} (source XML path with -keep-xml)")
+	flag.StringVar(&config.webhookURL, "webhook", "", "URL to POST the JSON file to when a new file is found")
+	flag.StringVar(&config.webhookHeaders, "webhook-headers", "", "JSON string of headers to include in webhook request (e.g. '{\"Authorization\":\"Bearer token\",\"X-API-Key\":\"key\"}')")
+	flag.BoolVar(&config.webhookMultipart, "webhook-multipart", false, "Send the webhook as multipart/form-data with the JSON attached as a file instead of a raw JSON body")
+	flag.BoolVar(&config.force, "force", false, "Force download even if the file already exists")
+	flag.BoolVar(&config.sync, "sync", false, "Run the exec command synchronously instead of in the background")
+	flag.BoolVar(&config.moneyAsCents, "money-as-cents", false, "Output monetary fields (fees, benefits, caps) as integer cents")
+	flag.StringVar(&config.traceItem, "trace-item", "", "Log the raw value, type and converted value of every field for the given ItemNum")
+	flag.BoolVar(&config.previewHTML, "preview-html", false, "Print the links found on the downloads and version pages and exit without downloading")
+	flag.BoolVar(&config.runLog, "run-log", false, "Append the outcome of each run to run.log in the downloads directory")
+	flag.Int64Var(&config.runLogMaxBytes, "run-log-max-bytes", 1<<20, "Rotate the run log once it reaches this size in bytes")
+	flag.IntVar(&config.runLogKeep, "run-log-keep", 5, "Number of rotated run log files to keep")
+	flag.BoolVar(&config.cacheBust, "cache-bust", false, "Send Cache-Control: no-cache on all requests to bypass caching proxies")
+	flag.StringVar(&config.historyCSV, "history-csv", "", "Write a wide CSV of ScheduleFee per item across all archived versions to this path and exit")
+	flag.StringVar(&config.dnsServer, "dns", "", "Fallback DNS server (e.g. 8.8.8.8) used when the system resolver fails")
+	flag.StringVar(&config.requireFields, "require-fields", "", "Comma-separated list of additional fields every item must have (e.g. ScheduleFee,Category)")
+	flag.BoolVar(&config.changelog, "changelog", false, "Append a section summarizing each new version to CHANGELOG.md in the downloads directory")
+	flag.BoolVar(&config.normalizeNumbers, "normalize-numbers", false, "Accept locale-formatted numbers in float fields (e.g. 1,234.50, 1.234,50 or 1234,50)")
+	flag.BoolVar(&config.checkpoint, "checkpoint", false, "Periodically checkpoint converted items and resume an interrupted conversion of the same source with the same conversion flags (the XML is still parsed again; only per-item conversion is resumed)")
+	flag.IntVar(&config.checkpointEvery, "checkpoint-every", 1000, "Number of items between checkpoints when -checkpoint is set")
+	flag.StringVar(&config.groupBy, "group-by", "", "Write item counts and total/average ScheduleFee per value of this field (e.g. Category or Group)")
+	flag.StringVar(&config.requiredSinks, "required-sinks", "", "Comma-separated sinks whose failure fails the run: changelog, group-by, exec, webhook, s3, sqlite-db (others only log a warning)")
+	flag.BoolVar(&config.strictSchema, "strict-schema", false, "Exit with code 3 when the data has new or missing fields compared to the built-in field definitions")
+	flag.StringVar(&config.schemaDriftWebhook, "schema-drift-webhook", "", "URL to POST a schema drift notification to when -strict-schema detects drift")
+	flag.StringVar(&config.format, "format", "json", "Output format: json, csv, ndjson or sqlite (json or text with -diff)")
+	flag.BoolVar(&config.allVersions, "all", false, "Download and convert every version on the downloads page that is not already in the downloads directory")
+	flag.StringVar(&config.since, "since", "", "Only download versions released in or after this month (YYYY-MM); implies -all")
+	flag.StringVar(&config.diff, "diff", "", "Report items added, removed and changed between two versions given as OLD,NEW (JSON file paths or YYYYMMDD dates) and exit")
+	flag.StringVar(&config.baseURL, "base-url", defaultBaseURL, "URL of the MBS downloads page; relative links are resolved against it")
+	flag.StringVar(&config.outDir, "out-dir", defaultDownloadPath, "Directory to write outputs, archived versions and logs to")
+	flag.IntVar(&config.retries, "retries", 3, "Maximum attempts for each page fetch and download; network errors and 5xx responses are retried with exponential backoff")
+	flag.Int64Var(&config.minXMLBytes, "min-xml-bytes", 1024, "Reject downloaded XML smaller than this many bytes as truncated")
+	flag.BoolVar(&config.watch, "watch", false, "Keep running and repeat the check/download/process cycle every -interval")
+	flag.DurationVar(&config.interval, "interval", time.Hour, "Time between cycles in -watch mode (e.g. 30m, 6h)")
+	flag.IntVar(&config.webhookRetries, "webhook-retries", 3, "Maximum webhook attempts; network errors and 5xx responses are retried with exponential backoff")
+	flag.DurationVar(&config.webhookTimeout, "webhook-timeout", 30*time.Second, "Timeout for each webhook attempt")
+	flag.StringVar(&config.webhookSecret, "webhook-secret", "", "Sign each webhook body with HMAC-SHA256 using this secret and send it in the X-Signature header as sha256=<hex>")
+	flag.BoolVar(&config.keepXML, "keep-xml", false, "Also save the downloaded XML to mbs_<date>.xml in the downloads directory")
+	flag.BoolVar(&config.dryRun, "dry-run", false, "Report the latest XML link and whether it is already downloaded, then exit 0 if up to date or 10 if a new version is available")
+	flag.BoolVar(&config.omitEmpty, "omit-empty", false, "Leave empty and missing fields out of each item instead of filling in zero values or null")
+	flag.StringVar(&config.logFormat, "log-format", "text", "Log output format: text or json (one object per line with level, timestamp, message and fields such as url and mbs_date)")
+	flag.StringVar(&config.statsFile, "stats-file", "", "Write a JSON summary of item counts, skip reasons and fields to this path after conversion")
+	flag.Float64Var(&config.maxSkipRatio, "max-skip-ratio", 0.05, "Abort without writing output when more than this fraction of items is skipped by validation (0 disables)")
+	flag.BoolVar(&config.printSchema, "print-schema", false, "Print a JSON Schema describing an output item, generated from the field definitions, and exit")
+	flag.Var(&config.filterCategory, "filter-category", "Only output items in these categories (comma-separated or repeated, case-insensitive)")
+	flag.Var(&config.filterGroup, "filter-group", "Only output items in these groups (comma-separated or repeated, case-insensitive)")
+	flag.StringVar(&config.proxy, "proxy", "", "Proxy URL for all HTTP requests (e.g. http://proxy.example.com:3128); defaults to HTTP_PROXY/HTTPS_PROXY")
+	flag.StringVar(&config.userAgent, "user-agent", defaultUserAgent, "User-Agent header sent with all HTTP requests")
+	flag.BoolVar(&config.quiet, "quiet", false, "Only log warnings and errors")
+	flag.BoolVar(&config.verbose, "verbose", false, "Also log debug messages, such as every link examined while scraping")
+	flag.BoolVar(&config.gzipOutput, "gzip-output", false, "Compress the output file with gzip, adding a .gz extension")
+	flag.BoolVar(&config.merge, "merge", false, "Combine every archived version by ItemNum into mbs_combined.json in the downloads directory, with the dates each item appeared and each field's value history, and exit")
+	flag.BoolVar(&config.failOnDuplicate, "fail-on-duplicate", false, "Fail validation when two items share an ItemNum instead of only logging a warning")
+	flag.DurationVar(&config.timeout, "timeout", 0, "Overall deadline for a run, or for each cycle in -watch mode (e.g. 10m); requests and a -sync command still running are cancelled (0 disables)")
+	flag.BoolVar(&config.triStateBooleans, "tri-state-booleans", false, "Output boolean fields as true for Y, false for N and null when empty or unrecognized, instead of false for anything but Y")
+	flag.StringVar(&config.uploadS3, "upload-s3", "", "Upload the output file (and the XML with -keep-xml) to this s3://bucket/prefix location, using the standard AWS credential chain")
+	flag.BoolVar(&config.s3Gzip, "s3-gzip", false, "Gzip each object uploaded by -upload-s3 and set Content-Encoding: gzip; the local files are left uncompressed")
+	flag.StringVar(&config.s3Endpoint, "s3-endpoint", "", "Endpoint URL of an S3-compatible store for -upload-s3 (e.g. http://localhost:9000); uses path-style addressing")
+	flag.BoolVar(&config.strict, "strict", false, "Skip items with out-of-range values, such as negative fees or percentage caps above 100, instead of only logging a warning")
+	flag.StringVar(&config.item, "item", "", "Print the item with this ItemNum from the latest version as JSON and exit, reading the downloaded file if there is one (exit code 7 if not found)")
+	flag.IntVar(&config.concurrency, "concurrency", 3, "Number of versions downloaded and converted in parallel with -all or -since")
+	flag.StringVar(&config.metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address (e.g. :9090) while running with -watch")
+	flag.StringVar(&config.fieldDefs, "field-defs", "", "JSON file mapping field names to {\"type\": \"string|boolean|date|float\", \"required\": bool}, merged over the built-in field definitions")
+	flag.BoolVar(&config.verifyManifest, "verify-manifest", false, "Check every file in manifest.json against its recorded size and SHA-256 and exit, failing if any is missing or modified")
+	flag.StringVar(&config.input, "input", "", "Convert this local MBS XML file instead of scraping and downloading, then write the output and run the sinks as usual")
+	flag.StringVar(&config.date, "date", "", "MBS date (YYYYMMDD) of the -input file, when it cannot be read from a file name like MBS-XML-20250101.XML")
+	flag.BoolVar(&config.webhookDelta, "webhook-delta", false, "Send the webhook only the items added, removed and changed since the previous archived version, falling back to the full output when there is none")
+	flag.BoolVar(&config.notifyNoChange, "notify-no-change", false, "When no new version is found, send the webhook a small {\"status\": \"up_to_date\"} notification so monitoring can tell the check ran")
+	flag.StringVar(&config.serveAddr, "serve", "", "Serve POST /convert on this address (e.g. :8080), converting the MBS XML request body to JSON, CSV, NDJSON or SQLite chosen by the Accept header")
+	flag.StringVar(&config.sqliteDB, "sqlite-db", "", "Load each new version's items into this long-lived SQLite database, adding columns for new fields")
+	flag.StringVar(&config.configFile, "config", "", "YAML or JSON file of settings keyed by flag name (e.g. webhook-headers: {Authorization: Bearer token}); command-line flags take precedence")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), exitCodeHelp)
 	}
+	flag.Parse()
 
-	// First pass: collect all unique fields across all items
-	allFields := make(map[string]bool)
-	for _, item := range items {
-		itemMap, ok := item.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		for field := range itemMap {
-			allFields[field] = true
+	if config.configFile != "" {
+		if err := applyConfigFile(flag.CommandLine, config.configFile); err != nil {
+			return withExitCode(exitUsage, err)
 		}
 	}
 
-	// Convert allFields to a slice for logging
-	var fieldNames []string
-	for field := range allFields {
-		fieldNames = append(fieldNames, field)
+	// Enable debug logging, as text or one JSON object per line
+	if config.logFormat != "text" && config.logFormat != "json" {
+		return withExitCode(exitUsage, fmt.Errorf("invalid -log-format %q: must be text or json", config.logFormat))
+	}
+	if config.quiet && config.verbose {
+		return withExitCode(exitUsage, fmt.Errorf("-quiet and -verbose cannot be used together"))
+	}
+	level := verbosityNormal
+	if config.quiet {
+		level = verbosityQuiet
+	} else if config.verbose {
+		level = verbosityVerbose
 	}
-	log.Printf("Found %d unique fields across all items: %v", len(fieldNames), fieldNames)
+	setupLogging(config.logFormat, level, os.Stderr)
 
-	// Second pass: validate and normalize items
-	var validItems []interface{}
-	for i, item := range items {
-		itemMap, ok := item.(map[string]interface{})
-		if !ok {
-			log.Printf("Warning: Skipping item at index %d: not an object", i)
-			continue
+	if config.fieldDefs != "" {
+		if err := loadFieldDefinitions(config.fieldDefs); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+	}
+	if config.groupBy != "" {
+		if err := checkGroupField(config.groupBy); err != nil {
+			return withExitCode(exitUsage, fmt.Errorf("invalid -group-by: %w", err))
 		}
+	}
 
-		// Check required fields have non-empty values
-		isValid := true
-		for field, info := range fieldDefinitions {
-			if !info.required {
-				continue
-			}
-			value, exists := itemMap[field]
-			if !exists {
-				log.Printf("Warning: Skipping item at index %d: missing required field '%s'", i, field)
-				isValid = false
-				break
-			}
-			strValue, ok := value.(string)
-			if !ok {
-				log.Printf("Warning: Skipping item at index %d: field '%s' is not a string", i, field)
-				isValid = false
-				break
-			}
-			if strValue == "" {
-				log.Printf("Warning: Skipping item at index %d: required field '%s' is empty", i, field)
-				isValid = false
-				break
-			}
+	// Describe the output items without fetching anything
+	if config.printSchema {
+		schema, err := json.MarshalIndent(mbs.JSONSchema(conversionOptions(config)), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format schema: %w", err)
 		}
+		fmt.Println(string(schema))
+		return nil
+	}
 
-		if !isValid {
-			continue
+	// Compare two existing versions without fetching anything
+	if config.diff != "" {
+		if config.format != "json" && config.format != "text" {
+			return withExitCode(exitUsage, fmt.Errorf("invalid -format %q for -diff: must be json or text", config.format))
+		}
+		report, err := buildDiffReport(config.diff)
+		if err != nil {
+			return fmt.Errorf("failed to compare versions: %w", err)
 		}
+		if err := writeDiffReport(os.Stdout, report, config.format); err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+		return nil
+	}
 
-		// Create new item with converted types
-		newItemMap := make(map[string]interface{})
-		for field := range allFields {
-			if value, exists := itemMap[field]; exists {
-				// Convert value to string first
-				strValue, ok := value.(string)
-				if !ok {
-					strValue = fmt.Sprintf("%v", value)
-				}
-				// Convert to appropriate type
-				newItemMap[field] = convertValue(field, strValue)
-			} else {
-				// Handle missing fields with appropriate zero values
-				newItemMap[field] = convertValue(field, "")
-			}
+	if config.execCmd != "" {
+		if _, err := splitCommandLine(config.execCmd); err != nil {
+			return withExitCode(exitUsage, fmt.Errorf("invalid -exec: %w", err))
 		}
+	}
 
-		// Add the normalized item to our valid items list
-		validItems = append(validItems, newItemMap)
+	if config.uploadS3 != "" {
+		if _, _, err := parseS3URL(config.uploadS3); err != nil {
+			return withExitCode(exitUsage, fmt.Errorf("invalid -upload-s3: %w", err))
+		}
+	}
+	if config.s3Gzip && config.uploadS3 == "" {
+		return withExitCode(exitUsage, fmt.Errorf("-s3-gzip requires -upload-s3"))
 	}
 
-	// Update the original data with normalized valid items
-	data["MBS_Items"] = validItems
+	if _, ok := outputFormats[config.format]; !ok {
+		return withExitCode(exitUsage, fmt.Errorf("invalid -format %q: must be json, csv, ndjson or sqlite", config.format))
+	}
 
-	log.Printf("JSON validation completed: %d valid items out of %d total items, %d fields per item", 
-		len(validItems), len(items), len(allFields))
-	return nil
-}
+	var since time.Time
+	if config.since != "" {
+		var err error
+		if since, err = time.Parse("2006-01", config.since); err != nil {
+			return withExitCode(exitUsage, fmt.Errorf("invalid -since %q: must be YYYY-MM", config.since))
+		}
+		config.allVersions = true
+	}
 
-func main() {
-	// Parse command line flags
-	config := Config{}
-	flag.StringVar(&config.execCmd, "exec", "", "Command to execute when a new file is found. Use {file} as placeholder for the JSON path")
-	flag.StringVar(&config.webhookURL, "webhook", "", "URL to POST the JSON file to when a new file is found")
-	flag.StringVar(&config.webhookHeaders, "webhook-headers", "", "JSON string of headers to include in webhook request (e.g. '{\"Authorization\":\"Bearer token\",\"X-API-Key\":\"key\"}')")
-	flag.BoolVar(&config.force, "force", false, "Force download even if the file already exists")
-	flag.BoolVar(&config.sync, "sync", false, "Run the exec command synchronously instead of in the background")
-	flag.Parse()
+	if config.notifyNoChange && config.webhookURL == "" {
+		return withExitCode(exitUsage, fmt.Errorf("-notify-no-change requires -webhook"))
+	}
+	if config.webhookDelta && config.webhookMultipart {
+		return withExitCode(exitUsage, fmt.Errorf("-webhook-delta cannot be combined with -webhook-multipart"))
+	}
+	// Earlier versions are only read back from the archived JSON files
+	if config.webhookDelta && config.format != "json" {
+		return withExitCode(exitUsage, fmt.Errorf("-webhook-delta requires -format json"))
+	}
+	if config.changelog && config.format != "json" {
+		return withExitCode(exitUsage, fmt.Errorf("-changelog requires -format json"))
+	}
+	if config.metricsAddr != "" && !config.watch {
+		return withExitCode(exitUsage, fmt.Errorf("-metrics-addr requires -watch"))
+	}
+	if config.concurrency < 1 {
+		return withExitCode(exitUsage, fmt.Errorf("-concurrency must be at least 1"))
+	}
+	if config.allVersions && config.checkpoint && config.concurrency > 1 {
+		log.Printf("Warning: -checkpoint uses a single checkpoint file, so versions are downloaded one at a time")
+		config.concurrency = 1
+	}
+
+	if config.dryRun && (config.allVersions || config.watch) {
+		return withExitCode(exitUsage, fmt.Errorf("-dry-run cannot be combined with -all, -since or -watch"))
+	}
+
+	downloadPath = config.outDir
+	client, err := newHTTPClient(config)
+	if err != nil {
+		return withExitCode(exitUsage, fmt.Errorf("invalid -proxy: %w", err))
+	}
+	httpClient = client
 
-	// Enable debug logging
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	requiredSinks, err := parseRequiredSinks(config.requiredSinks)
+	if err != nil {
+		return withExitCode(exitUsage, fmt.Errorf("invalid -required-sinks: %w", err))
+	}
 
 	// Create downloads directory if it doesn't exist
 	if err := os.MkdirAll(downloadPath, 0755); err != nil {
-		log.Fatal("Failed to create downloads directory:", err)
+		writeRunLog(config, RunRecord{Action: "failed", Errors: []string{fmt.Sprintf("failed to create downloads directory: %v", err)}})
+		return fmt.Errorf("failed to create downloads directory: %w", err)
+	}
+
+	// Build the fee history report from archived versions without fetching anything
+	if config.historyCSV != "" {
+		if err := writeFeeHistoryCSV(config.historyCSV); err != nil {
+			return fmt.Errorf("failed to write fee history CSV: %w", err)
+		}
+		return nil
+	}
+
+	// Check the downloaded files against the manifest without fetching anything
+	if config.verifyManifest {
+		return verifyManifest()
+	}
+
+	// Combine the archived versions into one file without fetching anything
+	if config.merge {
+		if _, err := writeMergedVersions(); err != nil {
+			return fmt.Errorf("failed to merge versions: %w", err)
+		}
+		return nil
+	}
+
+	// Convert XML posted over HTTP until stopped, without scraping or downloading anything
+	if config.serveAddr != "" {
+		if config.allVersions || config.watch || config.dryRun || config.item != "" || config.input != "" {
+			return withExitCode(exitUsage, fmt.Errorf("-serve cannot be combined with -all, -since, -watch, -dry-run, -item or -input"))
+		}
+		return serve(config)
+	}
+
+	// Convert a local XML file without scraping or downloading anything
+	if config.input != "" {
+		if config.allVersions || config.watch || config.dryRun || config.item != "" || config.previewHTML {
+			return withExitCode(exitUsage, fmt.Errorf("-input cannot be combined with -all, -since, -watch, -dry-run, -item or -preview-html"))
+		}
+		mbsDate, err := inputDate(config)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+		ctx, cancel := timeoutContext(config)
+		defer cancel()
+		return convertLocalXML(ctx, config, requiredSinks, mbsDate)
+	}
+	if config.date != "" {
+		return withExitCode(exitUsage, fmt.Errorf("-date requires -input"))
+	}
+
+	// Print a single item of the latest version without writing any output
+	if config.item != "" {
+		if config.allVersions || config.watch || config.dryRun {
+			return withExitCode(exitUsage, fmt.Errorf("-item cannot be combined with -all, -since, -watch or -dry-run"))
+		}
+		ctx, cancel := timeoutContext(config)
+		defer cancel()
+		return printItem(ctx, config, config.item, os.Stdout)
+	}
+
+	if config.watch {
+		if config.interval <= 0 {
+			return withExitCode(exitUsage, fmt.Errorf("invalid -interval %v: must be positive", config.interval))
+		}
+		if config.metricsAddr != "" {
+			if err := serveMetrics(config.metricsAddr); err != nil {
+				return err
+			}
+		}
+		watch(config, requiredSinks, since)
+		return nil
+	}
+
+	return runCycleWithTimeout(config, requiredSinks, since)
+}
+
+// errNewVersion is returned by runCycle with -dry-run when the latest version has not been downloaded
+var errNewVersion = errors.New("new version available")
+
+// errSchemaDrift is returned by runCycle when -strict-schema is set and the new version's fields drifted
+var errSchemaDrift = errors.New("schema drift detected")
+
+// runCycleWithTimeout runs one cycle, cancelling it at the -timeout deadline if one is set.
+// The context is not tied to the -watch shutdown signal, so a signal lets the cycle finish.
+func runCycleWithTimeout(config Config, requiredSinks map[string]bool, since time.Time) error {
+	ctx, cancel := timeoutContext(config)
+	defer cancel()
+	err := runCycle(ctx, config, requiredSinks, since)
+	if err == nil || errors.Is(err, errUpToDate) {
+		metrics.recordSuccess()
 	}
+	return err
+}
 
-	// Get the main downloads page
-	doc, err := fetchPage(baseURL)
+// timeoutContext returns a context that is cancelled at the -timeout deadline, if one is set
+func timeoutContext(config Config) (context.Context, context.CancelFunc) {
+	if config.timeout > 0 {
+		return context.WithTimeout(context.Background(), config.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runCycle performs one check-latest/download/process cycle and records its outcome in the run log
+func runCycle(ctx context.Context, config Config, requiredSinks map[string]bool, since time.Time) error {
+	// Record the outcome of the cycle, writing it to the run log before returning an error
+	record := RunRecord{Action: "failed"}
+	fail := func(err error) error {
+		record.Errors = append(record.Errors, err.Error())
+		writeRunLog(config, record)
+		return err
+	}
+
+	// Get the main downloads page. A normal run revalidates the copy seen by the last
+	// successful run, so an unchanged page needs no further requests.
+	var pageCache PageCache
+	conditional := !config.force && !config.allVersions && !config.dryRun && !config.previewHTML
+	if conditional {
+		pageCache = loadPageCache()
+	}
+	// Record a cycle that found nothing new, notifying the webhook with -notify-no-change
+	upToDate := func(mbsDate string) error {
+		if config.notifyNoChange {
+			if err := sendNoChangeWebhook(ctx, config, mbsDate); err != nil {
+				log.Printf("Warning: No-change webhook failed: %v", err)
+				record.Errors = append(record.Errors, fmt.Sprintf("no-change webhook failed: %v", err))
+			}
+		}
+		record.Action = "skipped"
+		writeRunLog(config, record)
+		return errUpToDate
+	}
+
+	doc, pageCache, err := fetchPageConditional(ctx, config.baseURL, config, pageCache)
+	if errors.Is(err, errNotModified) {
+		slog.Info("Downloads page not modified since the last check, skipping (use -force to override)", "url", config.baseURL)
+		// The page was not fetched, so report the newest version already downloaded
+		var mbsDate string
+		if versions, err := listArchivedVersions(); err == nil && len(versions) > 0 {
+			mbsDate = versions[len(versions)-1].Date
+		}
+		return upToDate(mbsDate)
+	}
 	if err != nil {
-		log.Fatal("Failed to fetch downloads page:", err)
+		return fail(withExitCode(exitNetwork, fmt.Errorf("failed to fetch downloads page: %w", err)))
+	}
+
+	if config.previewHTML {
+		previewLinks(config.baseURL, doc)
+	}
+
+	// Walk the whole back-catalogue; each version is recorded in the run log separately
+	if config.allVersions && !config.previewHTML {
+		if err := downloadAllVersions(ctx, config, requiredSinks, doc, since); err != nil {
+			return fmt.Errorf("failed to download versions: %w", err)
+		}
+		return nil
 	}
 
 	// Find the most recent MBS link
-	latestLink := findLatestMBSLink(doc)
+	latestLink := mbs.FindLatestLink(doc, config.baseURL, mbs.Hooks{})
 	if latestLink == "" {
-		log.Fatal("Could not find latest MBS link")
+		return fail(withExitCode(exitParse, fmt.Errorf("could not find latest MBS link")))
+	}
+	slog.Info("Found latest link", "url", latestLink)
+	if config.previewHTML {
+		fmt.Printf("Selected latest link: %s\n\n", latestLink)
 	}
-	log.Printf("Found latest link: %s", latestLink)
 
 	// Get the download page
-	downloadDoc, err := fetchPage(latestLink)
+	downloadDoc, err := fetchPage(ctx, latestLink, config)
 	if err != nil {
-		log.Fatal("Failed to fetch download page:", err)
+		return fail(withExitCode(exitNetwork, fmt.Errorf("failed to fetch download page: %w", err)))
+	}
+
+	if config.previewHTML {
+		previewLinks(latestLink, downloadDoc)
+		fmt.Printf("Selected XML link: %s\n", mbs.FindXMLLink(downloadDoc, config.baseURL))
+		return nil
 	}
 
 	// Find the XML download link
-	xmlLink := findXMLDownloadLink(downloadDoc)
+	xmlLink := mbs.FindXMLLink(downloadDoc, config.baseURL)
 	if xmlLink == "" {
-		log.Fatal("Could not find XML download link")
+		return fail(withExitCode(exitParse, fmt.Errorf("could not find XML download link")))
 	}
-	log.Printf("Found XML link: %s", xmlLink)
+	slog.Info("Found XML link", "url", xmlLink)
 
 	// Extract date from XML link
-	mbsDate, err := extractDateFromXMLLink(xmlLink)
+	mbsDate, err := mbs.XMLLinkDate(xmlLink)
 	if err != nil {
-		log.Fatal("Failed to extract date from XML link:", err)
+		return fail(withExitCode(exitParse, fmt.Errorf("failed to extract date from XML link: %w", err)))
 	}
+	record.MBSDate = mbsDate
 
 	// Check if we already have this version
 	hasVersion, err := hasLatestVersion(mbsDate)
 	if err != nil {
-		log.Fatal("Failed to check for existing version:", err)
+		return fail(fmt.Errorf("failed to check for existing version: %w", err))
+	}
+
+	// Report what would happen and stop before anything is downloaded or delivered
+	if config.dryRun {
+		fmt.Printf("Latest XML link: %s\n", xmlLink)
+		fmt.Printf("MBS date: %s\n", mbsDate)
+		if hasVersion {
+			fmt.Println("Status: already downloaded")
+			return nil
+		}
+		fmt.Println("Status: new version available")
+		return errNewVersion
 	}
 
 	if hasVersion && !config.force {
-		log.Printf("Already have MBS version %s, skipping download (use -force to override)", mbsDate)
-		return
+		slog.Info("Already have this MBS version, skipping download (use -force to override)", "mbs_date", mbsDate)
+		if conditional {
+			savePageCache(pageCache)
+		}
+		return upToDate(mbsDate)
 	}
 
 	// Download and process the XML file
-	if err := downloadAndConvertXML(xmlLink); err != nil {
-		log.Fatal("Failed to process XML:", err)
+	result, err := downloadAndConvertXML(ctx, xmlLink, config)
+	if err != nil {
+		return fail(fmt.Errorf("failed to process XML: %w", err))
 	}
+	// The version on this page is now downloaded, so the page can be revalidated next time
+	savePageCache(pageCache)
+	if err := completeCycle(ctx, config, requiredSinks, result, record); err != nil {
+		return err
+	}
+	fmt.Println("Successfully downloaded and converted MBS data!")
+	return nil
+}
 
-	// Get the path of the newly created JSON file
-	jsonPath := filepath.Join(downloadPath, fmt.Sprintf("mbs_%s.json", mbsDate))
-
-	// Execute command if specified
-	if config.execCmd != "" {
-		if err := executeCommand(config.execCmd, jsonPath, config.sync); err != nil {
-			log.Printf("Warning: Command execution failed: %v", err)
-		}
+// completeCycle writes the stats file, delivers the sinks and enforces -strict-schema for a newly
+// converted version, then writes the run log record
+func completeCycle(ctx context.Context, config Config, requiredSinks map[string]bool, result ConversionResult, record RunRecord) error {
+	fail := func(err error) error {
+		record.Errors = append(record.Errors, err.Error())
+		writeRunLog(config, record)
+		return err
 	}
 
-	// Send webhook if specified
-	if config.webhookURL != "" {
-		if err := sendWebhook(config.webhookURL, config.webhookHeaders, jsonPath); err != nil {
-			log.Printf("Warning: Webhook failed: %v", err)
+	mbsDate := record.MBSDate
+	summary := result.Summary
+	record.TotalItems = summary.TotalItems
+	record.ValidItems = summary.ValidItems
+
+	if config.statsFile != "" {
+		if err := writeStatsFile(config.statsFile, mbsDate, summary); err != nil {
+			return fail(err)
 		}
 	}
 
-	fmt.Println("Successfully downloaded and converted MBS data!")
-}
+	record.Action = "downloaded"
 
-func fetchPage(url string) (*goquery.Document, error) {
-	log.Printf("Fetching page: %s", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+	// Deliver the output to each configured sink; the output file itself is always critical
+	sinkErrors, failedRequired := deliverSinks(buildSinks(ctx, config, requiredSinks, mbsDate, result))
+	record.Errors = append(record.Errors, sinkErrors...)
+	if len(failedRequired) > 0 {
+		return fail(fmt.Errorf("required sinks failed: %s", strings.Join(failedRequired, ", ")))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+	if summary.SchemaDrifted() && config.strictSchema {
+		reportSchemaDrift(ctx, config, mbsDate, summary, &record)
+		writeRunLog(config, record)
+		return errSchemaDrift
 	}
 
-	return goquery.NewDocumentFromReader(resp.Body)
+	writeRunLog(config, record)
+	return nil
 }
 
-func findLatestMBSLink(doc *goquery.Document) string {
-	var latestLink string
-	var latestDate time.Time
+// reportSchemaDrift sends the -schema-drift-webhook notification for a drifted version, if one is
+// configured, and records the drift in the run record
+func reportSchemaDrift(ctx context.Context, config Config, mbsDate string, summary mbs.ValidationSummary, record *RunRecord) {
+	if config.schemaDriftWebhook != "" {
+		if err := sendSchemaDriftWebhook(ctx, config.schemaDriftWebhook, mbsDate, summary); err != nil {
+			log.Printf("Warning: Schema drift webhook failed: %v", err)
+			record.Errors = append(record.Errors, fmt.Sprintf("schema drift webhook failed: %v", err))
+		}
+	}
+	record.Errors = append(record.Errors, errSchemaDrift.Error())
+}
 
-	// Regular expression to match month year format
-	dateRegex := regexp.MustCompile(`(January|February|March|April|May|June|July|August|September|October|November|December)\s+\d{4}`)
+// httpGet performs a GET request with any extra headers, bypassing intermediate caches when
+// -cache-bust is set. Network errors and 5xx responses are retried up to -retries attempts in total.
+func httpGet(ctx context.Context, url string, config Config, header http.Header) (*http.Response, error) {
+	attempts := config.retries
+	if attempts < 1 {
+		attempts = 1
+	}
 
-	// Look for links containing dates
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists {
-			return
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
 		}
 
-		text := s.Text()
-		log.Printf("Examining link: text='%s', href='%s'", text, href)
+		if config.cacheBust {
+			req.Header.Set("Cache-Control", "no-cache")
+			req.Header.Set("Pragma", "no-cache")
+			slog.Info("Cache busting applied to request", "url", url)
+		}
 
-		// Look for text containing dates
-		if match := dateRegex.FindString(text); match != "" {
-			date, err := time.Parse("January 2006", match)
-			if err == nil && (latestDate.IsZero() || date.After(latestDate)) {
-				latestDate = date
-				latestLink = href
-				log.Printf("Found potential latest link: %s (date: %s)", href, date)
-			}
+		resp, err := httpClient.Do(req)
+		if attempt == attempts || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
 		}
-	})
 
-	// If the link is relative, make it absolute
-	if latestLink != "" && !strings.HasPrefix(latestLink, "http") {
-		if strings.HasPrefix(latestLink, "/") {
-			latestLink = "https://www.mbsonline.gov.au" + latestLink
-		} else {
-			latestLink = "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/" + latestLink
+		attrs := []any{"url", url, "attempt", attempt, "attempts", attempts}
+		if err == nil {
+			err = fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+			attrs = append(attrs, "status", resp.StatusCode)
+			resp.Body.Close()
+		}
+		delay := retryDelay(attempt)
+		slog.Warn("Request failed, retrying", append(attrs, "error", err, "delay", delay.Round(time.Millisecond))...)
+		if sleepContext(ctx, delay) != nil {
+			return nil, err
 		}
 	}
-
-	return latestLink
 }
 
-func findXMLDownloadLink(doc *goquery.Document) string {
-	var xmlLink string
-	// Regular expression to match MBS XML files
-	mbsXMLRegex := regexp.MustCompile(`(?i)MBS-XML-\d{8}\.XML$`)
+func fetchPage(ctx context.Context, url string, config Config) (*goquery.Document, error) {
+	doc, _, err := fetchPageConditional(ctx, url, config, PageCache{})
+	return doc, err
+}
 
+// previewLinks prints every link on a page with its text so the scraper's choices can be inspected
+func previewLinks(pageURL string, doc *goquery.Document) {
+	fmt.Printf("Links on %s:\n", pageURL)
+	count := 0
 	doc.Find("a").Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists {
 			return
 		}
-
-		text := strings.ToLower(s.Text())
-		log.Printf("Examining download link: text='%s', href='%s'", text, href)
-		
-		// Look for links that match the MBS XML pattern
-		if mbsXMLRegex.MatchString(href) || mbsXMLRegex.MatchString(text) || strings.Contains(text, "mbs-xml") {
-			// If the link contains a File directory, it's likely the correct one
-			if strings.Contains(href, "/$File/") {
-				xmlLink = href
-				log.Printf("Found MBS XML link: %s", href)
-			}
-		}
+		count++
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		fmt.Printf("  [%d] text=%q href=%q\n", count, text, href)
 	})
+	fmt.Printf("%d links found\n\n", count)
+}
 
-	// If the link is relative, make it absolute
-	if xmlLink != "" && !strings.HasPrefix(xmlLink, "http") {
-		if strings.HasPrefix(xmlLink, "/") {
-			xmlLink = "https://www.mbsonline.gov.au" + xmlLink
-		} else {
-			xmlLink = "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/" + xmlLink
-		}
+// conversionOptions maps the conversion flags onto mbs.Options
+func conversionOptions(config Config) mbs.Options {
+	opts := mbs.Options{
+		MoneyAsCents:      config.moneyAsCents,
+		NormalizeNumbers:  config.normalizeNumbers,
+		EmptyFloatsAsNull: config.format == "csv" || config.format == "sqlite",
+		TraceItem:         config.traceItem,
+		OmitEmpty:         config.omitEmpty,
+		MaxSkipRatio:      config.maxSkipRatio,
+		FailOnDuplicate:   config.failOnDuplicate,
+		TriStateBooleans:  config.triStateBooleans,
+		Strict:            config.strict,
 	}
-
-	return xmlLink
+	if config.requireFields != "" {
+		opts.RequireFields = strings.Split(config.requireFields, ",")
+	}
+	return opts
 }
 
-func downloadAndConvertXML(url string) error {
-	log.Printf("Downloading XML from: %s", url)
-	
-	// Extract date from URL for the filename
-	mbsDate, err := extractDateFromXMLLink(url)
-	if err != nil {
-		return fmt.Errorf("failed to extract date from URL: %w", err)
+// checkXMLContent rejects a download that is smaller than minBytes or does not start
+// with an XML declaration or the <MBS_XML> root element
+func checkXMLContent(data []byte, minBytes int64) error {
+	if int64(len(data)) < minBytes {
+		return fmt.Errorf("downloaded content is only %d bytes, below the minimum of %d (truncated download?)", len(data), minBytes)
 	}
 
-	// Download XML file
-	resp, err := http.Get(url)
+	start := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(start, []byte("<?xml")) || bytes.HasPrefix(start, []byte("<MBS_XML")) {
+		return nil
+	}
+	lower := bytes.ToLower(start)
+	if bytes.HasPrefix(lower, []byte("<!doctype html")) || bytes.HasPrefix(lower, []byte("<html")) {
+		return fmt.Errorf("content does not look like MBS XML (got HTML?)")
+	}
+	return fmt.Errorf("content does not look like MBS XML (starts with %q)", string(start[:min(len(start), 40)]))
+}
+
+// ConversionResult describes the output of a successful downloadAndConvertXML
+type ConversionResult struct {
+	Summary    mbs.ValidationSummary
+	Items      []map[string]interface{}
+	OutputPath string
+	XMLPath    string // Source XML saved by -keep-xml, empty otherwise
+}
+
+// downloadXML downloads an MBS XML file, decompressing a gzip-encoded response, and checks
+// that the download is complete and looks like MBS XML
+func downloadXML(ctx context.Context, url string, config Config) ([]byte, error) {
+	slog.Info("Downloading XML", "url", url)
+	start := time.Now()
+
+	// Download XML file. Requesting gzip explicitly turns off the transport's transparent
+	// decompression, so the Content-Length check below sees the bytes actually transferred.
+	resp, err := httpGet(ctx, url, config, http.Header{"Accept-Encoding": {"gzip"}})
 	if err != nil {
-		return fmt.Errorf("failed to download XML: %w", err)
+		return nil, withExitCode(exitNetwork, fmt.Errorf("failed to download XML: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("XML download failed with status: %d", resp.StatusCode)
+		return nil, withExitCode(exitNetwork, fmt.Errorf("XML download failed with status: %d", resp.StatusCode))
 	}
 
-	// Read the XML content
-	xmlData, err := io.ReadAll(resp.Body)
+	// Read the XML content, rejecting a body cut short of its Content-Length
+	xmlData, err := mbs.ReadDownload(resp, start, mbs.Hooks{})
 	if err != nil {
-		return fmt.Errorf("failed to read XML data: %w", err)
+		return nil, withExitCode(exitNetwork, err)
 	}
 
-	log.Printf("Successfully downloaded XML (%d bytes)", len(xmlData))
+	metrics.observeDownload(time.Since(start))
 
-	// Convert XML to JSON
-	jsonData, err := xml2json.Convert(bytes.NewReader(xmlData))
-	if err != nil {
-		return fmt.Errorf("failed to convert XML to JSON: %w", err)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		compressedSize := len(xmlData)
+		xmlData, err = gunzip(xmlData)
+		if err != nil {
+			return nil, withExitCode(exitParse, fmt.Errorf("failed to decompress XML data: %w", err))
+		}
+		slog.Info("Decompressed gzip XML response", "url", url, "compressed_bytes", compressedSize)
 	}
 
-	// Parse the JSON to modify its structure
-	var rawJSON map[string]interface{}
-	if err := json.Unmarshal(jsonData.Bytes(), &rawJSON); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+	slog.Info("Downloaded XML", "url", url, "bytes", len(xmlData))
+
+	// Reject truncated downloads and error pages served with a 200 status before converting
+	if err := checkXMLContent(xmlData, config.minXMLBytes); err != nil {
+		return nil, withExitCode(exitParse, err)
 	}
 
-	// Extract and rename the data
-	mbsXML, ok := rawJSON["MBS_XML"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("unexpected JSON structure: missing MBS_XML object")
+	return xmlData, nil
+}
+
+func downloadAndConvertXML(ctx context.Context, url string, config Config) (ConversionResult, error) {
+	var result ConversionResult
+
+	// Extract date from URL for the filename
+	mbsDate, err := mbs.XMLLinkDate(url)
+	if err != nil {
+		return result, withExitCode(exitParse, fmt.Errorf("failed to extract date from URL: %w", err))
 	}
 
-	data, ok := mbsXML["Data"]
-	if !ok {
-		return fmt.Errorf("unexpected JSON structure: missing Data object")
+	xmlData, err := downloadXML(ctx, url, config)
+	if err != nil {
+		return result, err
 	}
+	return convertXML(xmlData, mbsDate, url, config)
+}
 
-	// Create new structure with renamed node
-	newJSON := map[string]interface{}{
-		"MBS_Items": data,
+// convertXML converts, validates and writes the MBS XML of the given version, recording source
+// (its URL or local path) in the manifest
+func convertXML(xmlData []byte, mbsDate string, source string, config Config) (ConversionResult, error) {
+	var result ConversionResult
+
+	// Keep the source XML for auditing and reprocessing, even if conversion fails
+	if config.keepXML {
+		xmlPath := filepath.Join(downloadPath, fmt.Sprintf("mbs_%s.xml", mbsDate))
+		if err := writeFileAtomic(xmlPath, xmlData, 0644); err != nil {
+			return result, fmt.Errorf("failed to save XML file: %w", err)
+		}
+		slog.Info("Saved source XML", "mbs_date", mbsDate, "path", xmlPath)
+		result.XMLPath = xmlPath
 	}
 
-	// Validate the JSON structure
-	if err := validateJSON(newJSON); err != nil {
-		return fmt.Errorf("JSON validation failed: %w", err)
+	// Resume from a checkpoint of this exact source if one was left by an interrupted run
+	sourceHash := sha256.Sum256(xmlData)
+	cp, err := openCheckpoint(config, hex.EncodeToString(sourceHash[:]))
+	if err != nil {
+		return result, err
+	}
+	if cp != nil {
+		defer cp.close()
+	}
+
+	// Convert the XML and validate the item structure
+	dataset, err := mbs.Convert(bytes.NewReader(xmlData))
+	if err != nil {
+		return result, withExitCode(exitParse, err)
+	}
+	opts := conversionOptions(config)
+	if cp != nil {
+		opts.Checkpoint = cp
+	}
+	if err := mbs.ValidateWithOptions(dataset, opts); err != nil {
+		return result, withExitCode(exitValidation, fmt.Errorf("JSON validation failed: %w", err))
+	}
+	summary := dataset.Summary()
+	metrics.recordConversion(summary)
+	slog.Info("Converted MBS version", "mbs_date", mbsDate, "item_count", summary.ValidItems)
+
+	// Keep only the requested categories and groups
+	if filter := itemFilter(config); filter != nil {
+		kept := dataset.FilterWithReason(filter)
+		summary = dataset.Summary()
+		slog.Info("Filtered items", "mbs_date", mbsDate, "item_count", kept, "valid_items", summary.ValidItems)
 	}
 
-	// Pretty print the modified JSON
-	var prettyJSON bytes.Buffer
-	encoder := json.NewEncoder(&prettyJSON)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(newJSON); err != nil {
-		return fmt.Errorf("failed to format JSON: %w", err)
+	// Serialize the items in the requested output format
+	output, err := encodeOutput(dataset, summary.Fields, config)
+	if err != nil {
+		return result, fmt.Errorf("failed to format %s output: %w", config.format, err)
 	}
 
 	// Generate filename with MBS date
-	filename := filepath.Join(downloadPath, fmt.Sprintf("mbs_%s.json", mbsDate))
+	filename := outputFilename(mbsDate, config.format)
+	if config.gzipOutput {
+		output, err = gzipBytes(output)
+		if err != nil {
+			return result, fmt.Errorf("failed to compress %s output: %w", config.format, err)
+		}
+		filename += ".gz"
+	}
 
-	// Save the JSON to file
-	if err := os.WriteFile(filename, prettyJSON.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to save JSON file: %w", err)
+	// Save the output to file, renaming it into place so readers never see a partial file
+	if err := writeFileAtomic(filename, output, 0644); err != nil {
+		return result, fmt.Errorf("failed to save %s file: %w", config.format, err)
 	}
 
-	fmt.Printf("Saved JSON data to: %s\n", filename)
-	return nil
+	if cp != nil {
+		cp.remove()
+	}
+
+	fmt.Printf("Saved %s data to: %s\n", strings.ToUpper(config.format), filename)
+	if err := updateManifest(mbsDate, source, filename); err != nil {
+		slog.Warn("Failed to update manifest", "mbs_date", mbsDate, "error", err)
+	}
+	result.Summary = summary
+	result.Items = dataset.Items()
+	result.OutputPath = filename
+	return result, nil
 } 
\ No newline at end of file
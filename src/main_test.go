@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mbsop/mbs"
+)
+
+func TestMain(m *testing.M) {
+	// Conversion and retries log every warning, which would bury the test output
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// convertFixture converts and validates the given <Data> elements with the default options
+func convertFixture(t *testing.T, data string) *mbs.Dataset {
+	t.Helper()
+	dataset, err := mbs.Convert(strings.NewReader("<MBS_XML>" + data + "</MBS_XML>"))
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if err := mbs.ValidateWithOptions(dataset, mbs.Options{}); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	return dataset
+}
+
+func TestHTTPGetRetriesServerErrors(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	resp, err := httpGet(context.Background(), server.URL, Config{retries: 3}, nil)
+	if err != nil {
+		t.Fatalf("httpGet: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200 after two 503s", resp.StatusCode)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server received %d requests, want 3", n)
+	}
+}
+
+func TestHTTPGetDoesNotRetryClientErrors(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	resp, err := httpGet(context.Background(), server.URL, Config{retries: 3}, nil)
+	if err != nil {
+		t.Fatalf("httpGet: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("server received %d requests for a 404, want 1", n)
+	}
+}
+
+func TestHTTPGetGivesUp(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	// Every attempt fails with a server error: the last response is returned after -retries attempts
+	var requests atomic.Int32
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	resp, err := httpGet(context.Background(), unavailable.URL, Config{retries: 3}, nil)
+	if err != nil {
+		t.Fatalf("httpGet: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want the last 503", resp.StatusCode)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server received %d requests, want 3", n)
+	}
+
+	// Every attempt fails with a network error: the last error is returned
+	requests.Store(0)
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	defer dropping.Close()
+
+	resp, err = httpGet(context.Background(), dropping.URL, Config{retries: 2}, nil)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("httpGet succeeded against a server that drops every connection")
+	}
+	if !strings.Contains(err.Error(), dropping.URL) {
+		t.Errorf("httpGet error = %v, want the request error for %s", err, dropping.URL)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestCheckXMLContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		minBytes int64
+		wantErr  string
+	}{
+		{"XML declaration", `<?xml version="1.0"?><MBS_XML></MBS_XML>`, 0, ""},
+		{"root element", `<MBS_XML></MBS_XML>`, 0, ""},
+		{"byte order mark and whitespace", "\xef\xbb\xbf\n  <?xml version=\"1.0\"?><MBS_XML/>", 0, ""},
+		{"HTML error page", `<!DOCTYPE html><html><body>Service unavailable</body></html>`, 0, "got HTML"},
+		{"HTML without doctype", `<HTML><body>Not found</body></HTML>`, 0, "got HTML"},
+		{"other content", `{"error":"not found"}`, 0, `starts with "{\"error\":\"not found\"}"`},
+		{"below the minimum size", `<MBS_XML/>`, 20, "below the minimum of 20"},
+	}
+	for _, tt := range tests {
+		err := checkXMLContent([]byte(tt.data), tt.minBytes)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: checkXMLContent = %v, want nil", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: checkXMLContent = %v, want an error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDownloadXMLTruncated(t *testing.T) {
+	const body = `<?xml version="1.0"?><MBS_XML><Data><ItemNum>23</ItemNum>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Declare the full length but drop the connection partway through the body
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/xml\r\nContent-Length: %d\r\n\r\n%s", len(body)+1000, body)
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	_, err := downloadXML(context.Background(), server.URL+"/MBS-XML-20250701.XML", Config{retries: 1})
+	if err == nil {
+		t.Fatal("downloadXML accepted a body shorter than its Content-Length")
+	}
+	if code := exitCode(err); code != exitNetwork {
+		t.Errorf("exit code = %d, want %d (network) for %v", code, exitNetwork, err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// manifestMu serializes manifest updates from the -all workers
+var manifestMu sync.Mutex
+
+// ManifestEntry records where a downloaded version came from and the checksum of its output file
+type ManifestEntry struct {
+	MBSDate      string `json:"mbs_date"`
+	SourceURL    string `json:"source_url"`
+	DownloadedAt string `json:"downloaded_at"`
+	File         string `json:"file"` // Relative to the downloads directory
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+}
+
+// Manifest lists every version converted into the downloads directory, oldest first
+type Manifest struct {
+	Versions []ManifestEntry `json:"versions"`
+}
+
+// readManifest reads the manifest from the downloads directory, returning an empty manifest if there is none
+func readManifest() (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(downloadPath, manifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of a file and its size
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// updateManifest records a newly written output file in the manifest, replacing any earlier entry
+// for the same MBS date and keeping the others. The manifest is rewritten atomically.
+func updateManifest(mbsDate string, sourceURL string, outputPath string) error {
+	checksum, size, err := fileSHA256(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", outputPath, err)
+	}
+	entry := ManifestEntry{
+		MBSDate:      mbsDate,
+		SourceURL:    sourceURL,
+		DownloadedAt: time.Now().UTC().Format(time.RFC3339),
+		File:         filepath.Base(outputPath),
+		Size:         size,
+		SHA256:       checksum,
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := readManifest()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i, existing := range manifest.Versions {
+		if existing.MBSDate == mbsDate {
+			manifest.Versions[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		manifest.Versions = append(manifest.Versions, entry)
+	}
+	sort.SliceStable(manifest.Versions, func(i, j int) bool {
+		return manifest.Versions[i].MBSDate < manifest.Versions[j].MBSDate
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format manifest: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(downloadPath, manifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// verifyManifest checks every file listed in the manifest against its recorded size and SHA-256,
+// logging each missing or modified file. It fails if any file does not match.
+func verifyManifest() error {
+	manifest, err := readManifest()
+	if err != nil {
+		return err
+	}
+	if len(manifest.Versions) == 0 {
+		return fmt.Errorf("no versions recorded in %s", filepath.Join(downloadPath, manifestName))
+	}
+
+	var failed int
+	for _, entry := range manifest.Versions {
+		path := filepath.Join(downloadPath, entry.File)
+		checksum, size, err := fileSHA256(path)
+		switch {
+		case os.IsNotExist(err):
+			slog.Error("Archived file is missing", "path", path, "mbs_date", entry.MBSDate)
+			failed++
+		case err != nil:
+			slog.Error("Failed to read archived file", "path", path, "mbs_date", entry.MBSDate, "error", err)
+			failed++
+		case size != entry.Size || checksum != entry.SHA256:
+			slog.Error("Archived file has been modified", "path", path, "mbs_date", entry.MBSDate,
+				"expected_bytes", entry.Size, "expected_sha256", entry.SHA256, "bytes", size, "sha256", checksum)
+			failed++
+		default:
+			slog.Debug("Verified archived file", "path", path, "mbs_date", entry.MBSDate)
+		}
+	}
+
+	slog.Info("Verified archived files", "files", len(manifest.Versions), "ok", len(manifest.Versions)-failed, "failed", failed)
+	if failed > 0 {
+		return withExitCode(exitValidation, fmt.Errorf("%d of %d files failed verification", failed, len(manifest.Versions)))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeVersionFile writes an output file for the given MBS date and records it in the manifest
+func writeVersionFile(t *testing.T, mbsDate, content string) string {
+	t.Helper()
+	path := filepath.Join(downloadPath, "mbs_"+mbsDate+".json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateManifest(mbsDate, "https://example.com/MBS-XML-"+mbsDate+".XML", path); err != nil {
+		t.Fatalf("updateManifest %s: %v", mbsDate, err)
+	}
+	return path
+}
+
+func TestUpdateManifestMerges(t *testing.T) {
+	downloadPath = t.TempDir()
+
+	writeVersionFile(t, "20250201", `{"version":2}`)
+	writeVersionFile(t, "20250101", `{"version":1}`)
+	// Reconverting a version replaces its entry instead of adding another
+	writeVersionFile(t, "20250201", `{"version":2,"corrected":true}`)
+
+	manifest, err := readManifest()
+	if err != nil {
+		t.Fatalf("readManifest: %v", err)
+	}
+	if len(manifest.Versions) != 2 {
+		t.Fatalf("manifest has %d entries, want 2: %+v", len(manifest.Versions), manifest.Versions)
+	}
+	if manifest.Versions[0].MBSDate != "20250101" || manifest.Versions[1].MBSDate != "20250201" {
+		t.Errorf("manifest dates = %s, %s, want oldest first", manifest.Versions[0].MBSDate, manifest.Versions[1].MBSDate)
+	}
+	if entry := manifest.Versions[1]; entry.Size != int64(len(`{"version":2,"corrected":true}`)) || entry.File != "mbs_20250201.json" {
+		t.Errorf("reconverted entry = %+v, want the size of the new file", entry)
+	}
+	if err := verifyManifest(); err != nil {
+		t.Errorf("verifyManifest on untouched files: %v", err)
+	}
+}
+
+func TestVerifyManifestDetectsChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(path string) error
+	}{
+		{"modified", func(path string) error { return os.WriteFile(path, []byte(`{"version":9}`), 0644) }},
+		{"missing", os.Remove},
+	}
+	for _, tt := range tests {
+		downloadPath = t.TempDir()
+		writeVersionFile(t, "20250101", `{"version":1}`)
+		path := writeVersionFile(t, "20250201", `{"version":2}`)
+		if err := tt.change(path); err != nil {
+			t.Fatal(err)
+		}
+
+		err := verifyManifest()
+		if err == nil || !strings.Contains(err.Error(), "1 of 2 files failed verification") {
+			t.Errorf("%s: verifyManifest = %v, want 1 of 2 files to fail", tt.name, err)
+		}
+		if code := exitCode(err); code != exitValidation {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, exitValidation)
+		}
+	}
+}
+
+func TestVerifyManifestEmpty(t *testing.T) {
+	downloadPath = t.TempDir()
+	if err := verifyManifest(); err == nil {
+		t.Error("verifyManifest with no manifest succeeded")
+	}
+}
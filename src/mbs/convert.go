@@ -0,0 +1,294 @@
+// Package mbs converts Medicare Benefits Schedule (MBS) XML into typed items.
+//
+// Convert parses the XML into a Dataset and Validate drops invalid items and converts
+// every field to its typed value according to the built-in field definitions:
+//
+//	dataset, err := mbs.Convert(r)
+//	if err != nil {
+//		return err
+//	}
+//	if err := mbs.Validate(dataset); err != nil {
+//		return err
+//	}
+//	for _, item := range dataset.Items() {
+//		fmt.Println(item["ItemNum"], item["ScheduleFee"])
+//	}
+package mbs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/basgys/goxml2json"
+)
+
+// Options controls how field values are converted during validation
+type Options struct {
+	MoneyAsCents      bool     // Emit monetary fields as integer cents instead of floats
+	NormalizeNumbers  bool     // Accept thousands separators and comma decimals in float fields
+	EmptyFloatsAsNull bool     // Convert empty float fields to null instead of 0
+	RequireFields     []string // Fields required in addition to those marked required in the field definitions
+	TraceItem         string   // ItemNum whose field conversions are logged in detail
+	OmitEmpty         bool     // Leave empty and missing fields out of items instead of filling in zero values
+	MaxSkipRatio      float64  // Fail validation when more than this fraction of items is skipped; 0 disables the check
+	FailOnDuplicate   bool     // Fail validation when two items share an ItemNum instead of only logging a warning
+	TriStateBooleans  bool     // Convert Y to true, N to false and empty or unrecognized boolean values to null
+	Strict            bool     // Skip items with out-of-range values instead of only logging a warning
+	Checkpoint        Checkpointer
+	Hooks             Hooks // Callbacks for observing the conversion
+}
+
+// Hooks are optional callbacks that let embedders observe a fetch and conversion and collect
+// their own metrics without parsing the logs. Nil hooks are skipped. They are called
+// synchronously from the goroutine running the function that reports them, so a slow hook
+// slows that step.
+type Hooks struct {
+	// OnLatestLinkFound is called by FindLatestLink with the link to the newest version
+	OnLatestLinkFound func(link string)
+	// OnDownloadComplete is called by ReadDownload with the URL, size in bytes and duration of
+	// a completed XML download
+	OnDownloadComplete func(url string, size int, elapsed time.Duration)
+	// OnItemConverted is called with the index in the source and the typed value of each item
+	// that passes validation. Items restored from a Checkpoint are not reported again.
+	OnItemConverted func(index int, item Item)
+	// OnValidationComplete is called with the summary once validation has succeeded
+	OnValidationComplete func(summary ValidationSummary)
+}
+
+// dateLayouts are the date formats accepted in DateType fields, tried in order.
+// Day and month may be written with or without a leading zero.
+var dateLayouts = []string{
+	"2.1.2006",   // DD.MM.YYYY, used by the MBS XML
+	"2/1/2006",   // DD/MM/YYYY
+	"2006-01-02", // YYYY-MM-DD
+}
+
+// toCents converts a dollar amount to integer cents, rounding to the nearest cent
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// thousandsGroupingRegex matches numbers that only use commas as thousands separators (e.g. 1,234,567)
+var thousandsGroupingRegex = regexp.MustCompile(`^-?\d{1,3}(,\d{3})+$`)
+
+// normalizeNumber rewrites locale-formatted numbers such as "1,234.50", "1.234,50" or "1234,50"
+// into the plain form accepted by strconv.ParseFloat
+func normalizeNumber(value string) string {
+	value = strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+
+	lastComma := strings.LastIndex(value, ",")
+	lastDot := strings.LastIndex(value, ".")
+
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		// Whichever separator comes last is the decimal point
+		if lastComma > lastDot {
+			value = strings.ReplaceAll(value, ".", "")
+			value = strings.Replace(value, ",", ".", 1)
+		} else {
+			value = strings.ReplaceAll(value, ",", "")
+		}
+	case lastComma >= 0:
+		if thousandsGroupingRegex.MatchString(value) {
+			value = strings.ReplaceAll(value, ",", "")
+		} else {
+			value = strings.Replace(value, ",", ".", 1)
+		}
+	case strings.Count(value, ".") > 1:
+		// Dots used as thousands separators (e.g. 1.234.567)
+		value = strings.ReplaceAll(value, ".", "")
+	}
+	return value
+}
+
+// cleanNumber strips surrounding whitespace, a leading currency symbol and thousands separators
+// from a float value. Commas are only removed when the integer part is grouped in thousands, so
+// "1234,50" is left to fail parsing rather than silently becoming 123450. With normalize set,
+// locale formats are handled by normalizeNumber instead, since a comma may then be the decimal
+// separator.
+func cleanNumber(value string, normalize bool) string {
+	value = strings.TrimSpace(value)
+
+	sign := ""
+	if strings.HasPrefix(value, "-") {
+		sign, value = "-", strings.TrimSpace(value[1:])
+	}
+	if r, size := utf8.DecodeRuneInString(value); unicode.Is(unicode.Sc, r) {
+		value = strings.TrimSpace(value[size:])
+	}
+	value = sign + value
+
+	if normalize {
+		return normalizeNumber(value)
+	}
+	if intPart, _, _ := strings.Cut(value, "."); thousandsGroupingRegex.MatchString(intPart) {
+		return strings.ReplaceAll(value, ",", "")
+	}
+	return value
+}
+
+// emptyAsNull reports whether an empty value of the field converts to null rather than a zero value
+func emptyAsNull(info FieldInfo, opts Options) bool {
+	return info.nullable ||
+		(info.fieldType == FloatType && opts.EmptyFloatsAsNull) ||
+		(info.fieldType == BooleanType && opts.TriStateBooleans)
+}
+
+// convertValue converts a string value to its appropriate type based on the field definition
+func convertValue(field string, value string, opts Options) interface{} {
+	// Get field info, default to string type if not defined
+	fieldInfo, exists := fieldDefinitions[field]
+	if !exists {
+		return value
+	}
+
+	// Handle empty values
+	if value == "" {
+		if emptyAsNull(fieldInfo, opts) {
+			return nil
+		}
+		switch fieldInfo.fieldType {
+		case BooleanType:
+			return false
+		case FloatType:
+			if opts.MoneyAsCents && monetaryFields[field] {
+				return int64(0)
+			}
+			return 0.0
+		default:
+			return ""
+		}
+	}
+
+	switch fieldInfo.fieldType {
+	case BooleanType:
+		if !opts.TriStateBooleans {
+			return strings.ToUpper(value) == "Y"
+		}
+		switch strings.ToUpper(strings.TrimSpace(value)) {
+		case "Y":
+			return true
+		case "N":
+			return false
+		}
+		log.Printf("Warning: Field '%s' has unrecognized boolean %q, using null", field, value)
+		return nil
+
+	case DateType:
+		// Parse the date with the first matching layout
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+				return t.Format("2006-01-02") // Convert to ISO 8601 format
+			}
+		}
+		log.Printf("Warning: Field '%s' has unrecognized date %q, using null", field, value)
+		return nil
+
+	case FloatType:
+		// Try to parse as float once formatting such as "$1,234.50" is removed
+		if f, err := strconv.ParseFloat(cleanNumber(value, opts.NormalizeNumbers), 64); err == nil {
+			if opts.MoneyAsCents && monetaryFields[field] {
+				return toCents(f)
+			}
+			return f
+		}
+		log.Printf("Warning: Field '%s' has non-numeric value %q, using 0", field, value)
+		if opts.MoneyAsCents && monetaryFields[field] {
+			return int64(0)
+		}
+		return 0.0
+
+	default:
+		return value
+	}
+}
+
+// Convert parses MBS XML and returns a Dataset holding the untyped items.
+// Call Validate on the result to drop invalid items and convert field types.
+func Convert(r io.Reader) (*Dataset, error) {
+	// Convert XML to JSON
+	jsonData, err := xml2json.Convert(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert XML to JSON: %w", err)
+	}
+
+	// Parse the JSON to modify its structure
+	var rawJSON map[string]interface{}
+	if err := json.Unmarshal(jsonData.Bytes(), &rawJSON); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	// Extract the data, searching for the item array if it has moved
+	data, err := extractItemData(rawJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dataset{raw: data}, nil
+}
+
+// extractItemData returns the item array from the converted JSON. It expects MBS_XML.Data,
+// but falls back to the first array whose elements contain an ItemNum field.
+func extractItemData(rawJSON map[string]interface{}) (interface{}, error) {
+	if mbsXML, ok := rawJSON["MBS_XML"].(map[string]interface{}); ok {
+		if data, ok := mbsXML["Data"]; ok {
+			// A file with a single item converts to an object rather than an array
+			if item, ok := data.(map[string]interface{}); ok {
+				return []interface{}{item}, nil
+			}
+			return data, nil
+		}
+	}
+
+	data, path, found := findItemArray(rawJSON, "")
+	if !found {
+		return nil, fmt.Errorf("unexpected JSON structure: missing MBS_XML.Data and no array of items with ItemNum found")
+	}
+	log.Printf("Warning: MBS_XML.Data not found, using item array discovered at %s", path)
+	return data, nil
+}
+
+// findItemArray walks the JSON tree in key order looking for an array of objects with an ItemNum field
+func findItemArray(node interface{}, path string) (interface{}, string, bool) {
+	switch v := node.(type) {
+	case []interface{}:
+		for _, element := range v {
+			if elementMap, ok := element.(map[string]interface{}); ok {
+				if _, hasItemNum := elementMap["ItemNum"]; hasItemNum {
+					return v, path, true
+				}
+			}
+		}
+		for i, element := range v {
+			if data, found, ok := findItemArray(element, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return data, found, true
+			}
+		}
+	case map[string]interface{}:
+		var keys []string
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			if data, found, ok := findItemArray(v[key], childPath); ok {
+				return data, found, true
+			}
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,191 @@
+package mbs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToCents(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   int64
+	}{
+		{75.05, 7505},
+		{0.1 + 0.2, 30},
+		{19.999, 2000},
+		{0, 0},
+		{1234.5, 123450},
+	}
+	for _, tt := range tests {
+		if got := toCents(tt.amount); got != tt.want {
+			t.Errorf("toCents(%v) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestConvertValueMoneyAsCents(t *testing.T) {
+	opts := Options{MoneyAsCents: true}
+	tests := []struct {
+		field string
+		value string
+		want  interface{}
+	}{
+		{"ScheduleFee", "75.05", int64(7505)},
+		{"Benefit75", "56.30", int64(5630)},
+		{"ScheduleFee", "", int64(0)},
+		{"ScheduleFee", "abc", int64(0)},
+		{"EMSNPercentageCap", "80", 80.0}, // Not monetary, so never scaled
+		{"BasicUnits", "5", 5.0},
+	}
+	for _, tt := range tests {
+		got := convertValue(tt.field, tt.value, opts)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertValue(%q, %q) = %#v, want %#v", tt.field, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConvertValueEmptyNullable(t *testing.T) {
+	tests := []struct {
+		fieldType FieldType
+		nullable  bool
+		want      interface{}
+	}{
+		{StringType, false, ""},
+		{StringType, true, nil},
+		{BooleanType, false, false},
+		{BooleanType, true, nil},
+		{DateType, false, ""},
+		{DateType, true, nil},
+		{FloatType, false, 0.0},
+		{FloatType, true, nil},
+	}
+	for _, tt := range tests {
+		field := fmt.Sprintf("Test%sNullable%v", tt.fieldType, tt.nullable)
+		fieldDefinitions[field] = FieldInfo{tt.fieldType, false, tt.nullable}
+		got := convertValue(field, "", Options{})
+		delete(fieldDefinitions, field)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("empty %s (nullable %v) = %#v, want %#v", tt.fieldType, tt.nullable, got, tt.want)
+		}
+	}
+}
+
+func TestConvertValueEmptyBuiltIn(t *testing.T) {
+	tests := []struct {
+		field string
+		opts  Options
+		want  interface{}
+	}{
+		{"ItemStartDate", Options{}, nil},
+		{"NewItem", Options{}, false},
+		{"NewItem", Options{TriStateBooleans: true}, nil},
+		{"ScheduleFee", Options{}, 0.0},
+		{"ScheduleFee", Options{EmptyFloatsAsNull: true}, nil},
+		{"Category", Options{}, ""},
+		{"UndefinedField", Options{}, ""},
+	}
+	for _, tt := range tests {
+		got := convertValue(tt.field, "", tt.opts)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertValue(%q, \"\", %+v) = %#v, want %#v", tt.field, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"1,234.50", "1234.50"},
+		{"1.234,50", "1234.50"},
+		{"1234,50", "1234.50"},
+		{"1.234.567", "1234567"},
+		{"1,234", "1234"},
+		{"12.5", "12.5"},
+		{" 1 234,5 ", "1234.5"},
+	}
+	for _, tt := range tests {
+		if got := normalizeNumber(tt.value); got != tt.want {
+			t.Errorf("normalizeNumber(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCleanNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"1,234.50", "1234.50"},
+		{"1,234,567", "1234567"},
+		{"$12.00", "12.00"},
+		{" 3.5 ", "3.5"},
+		{"-$1,234.50", "-1234.50"},
+		{"1234,50", "1234,50"}, // Not a thousands grouping, so left to fail parsing
+		{"12,34.5", "12,34.5"},
+		{"abc", "abc"},
+	}
+	for _, tt := range tests {
+		if got := cleanNumber(tt.value, false); got != tt.want {
+			t.Errorf("cleanNumber(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConvertValueFloat(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{"1,234.50", 1234.5},
+		{"$12.00", 12.0},
+		{" 3.5 ", 3.5},
+		{"1234,50", 0.0},
+		{"abc", 0.0},
+	}
+	for _, tt := range tests {
+		if got := convertValue("ScheduleFee", tt.value, Options{}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertValue(ScheduleFee, %q) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConvertValueInvalidFloatWarns(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(io.Discard)
+
+	convertValue("ScheduleFee", "1234,50", Options{})
+	if !strings.Contains(logged.String(), `Warning: Field 'ScheduleFee' has non-numeric value "1234,50"`) {
+		t.Errorf("no warning logged for a comma decimal, got %q", logged.String())
+	}
+}
+
+func TestConvertValueDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{"01.07.2025", "2025-07-01"}, // DD.MM.YYYY
+		{"1.7.2025", "2025-07-01"},
+		{"01/07/2025", "2025-07-01"}, // DD/MM/YYYY
+		{"1/7/2025", "2025-07-01"},
+		{"2025-07-01", "2025-07-01"}, // YYYY-MM-DD
+		{" 01.07.2025 ", "2025-07-01"},
+		{"July 2025", nil},
+		{"31.02.2025", nil},
+		{"07/31/2025", nil}, // Month first is not accepted
+	}
+	for _, tt := range tests {
+		if got := convertValue("ItemStartDate", tt.value, Options{}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertValue(ItemStartDate, %q) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
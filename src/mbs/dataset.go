@@ -0,0 +1,77 @@
+package mbs
+
+import "encoding/json"
+
+// Item is a single MBS item keyed by field name. After validation, values are typed:
+// strings, booleans, ISO 8601 date strings, float64 (or int64 cents) and nil.
+type Item = map[string]interface{}
+
+// Dataset holds the items of one MBS XML file
+type Dataset struct {
+	raw       interface{} // Item data as converted from XML, before validation
+	items     []Item
+	summary   ValidationSummary
+	validated bool
+}
+
+// Items returns the validated, typed items. Before Validate is called it returns
+// the raw items with their string values.
+func (d *Dataset) Items() []Item {
+	if d.validated {
+		return d.items
+	}
+
+	raw, _ := d.raw.([]interface{})
+	items := make([]Item, 0, len(raw))
+	for _, item := range raw {
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			items = append(items, itemMap)
+		}
+	}
+	return items
+}
+
+// Summary returns the counts and fields recorded by Validate
+func (d *Dataset) Summary() ValidationSummary {
+	return d.summary
+}
+
+// MarshalJSON encodes the dataset as {"MBS_Items": [...]}
+func (d *Dataset) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"MBS_Items": d.Items(),
+	})
+}
+
+// Filter keeps only the items for which keep returns true and returns the number kept.
+// Dropped items are counted under the reason "FILTERED". It applies to the validated items,
+// so call it after Validate.
+func (d *Dataset) Filter(keep func(item Item) bool) int {
+	return d.FilterWithReason(func(item Item) string {
+		if keep(item) {
+			return ""
+		}
+		return "FILTERED"
+	})
+}
+
+// FilterWithReason drops the items for which reason returns a non-empty string, counting each
+// reason in the summary's FilterReasons, and returns the number kept. It applies to the
+// validated items, so call it after Validate.
+func (d *Dataset) FilterWithReason(reason func(item Item) string) int {
+	items := d.Items()
+	kept := make([]Item, 0, len(items))
+	if d.summary.FilterReasons == nil {
+		d.summary.FilterReasons = make(map[string]int)
+	}
+	for _, item := range items {
+		if r := reason(item); r != "" {
+			d.summary.FilterReasons[r]++
+			continue
+		}
+		kept = append(kept, item)
+	}
+	d.items = kept
+	d.validated = true
+	return len(kept)
+}
@@ -0,0 +1,100 @@
+package mbs_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"mbsop/mbs"
+)
+
+func ExampleHooks() {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	const xml = `<MBS_XML>
+<Data><ItemNum>3</ItemNum><Description>Short consultation</Description><ScheduleFee>19.60</ScheduleFee></Data>
+<Data><ItemNum>23</ItemNum><Description>Standard consultation</Description><ScheduleFee>42.85</ScheduleFee></Data>
+<Data><ItemNum></ItemNum><Description>No item number</Description></Data>
+</MBS_XML>`
+
+	// A stand-in for MBS Online: the downloads page, one version page and its XML file
+	pages := map[string]string{
+		"/downloads":        `<a href="/downloads-202507">July 2025</a><a href="/downloads-202504">April 2025</a>`,
+		"/downloads-202507": `<a href="/downloads-202507/$File/MBS-XML-20250701.XML">MBS-XML-20250701.XML</a>`,
+		"/downloads-202507/$File/MBS-XML-20250701.XML": xml,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	hooks := mbs.Hooks{
+		OnLatestLinkFound: func(link string) {
+			fmt.Printf("latest version: %s\n", strings.TrimPrefix(link, server.URL))
+		},
+		OnDownloadComplete: func(url string, size int, elapsed time.Duration) {
+			fmt.Printf("downloaded %s (%d bytes)\n", strings.TrimPrefix(url, server.URL), size)
+		},
+		OnItemConverted: func(index int, item mbs.Item) {
+			fmt.Printf("converted item %v at index %d: fee %v\n", item["ItemNum"], index, item["ScheduleFee"])
+		},
+		OnValidationComplete: func(summary mbs.ValidationSummary) {
+			fmt.Printf("%d of %d items valid\n", summary.ValidItems, summary.TotalItems)
+		},
+	}
+
+	fetch := func(url string) (*goquery.Document, error) {
+		resp, err := http.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return goquery.NewDocumentFromReader(resp.Body)
+	}
+	doc, err := fetch(server.URL + "/downloads")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	versionDoc, err := fetch(mbs.FindLatestLink(doc, server.URL+"/downloads", hooks))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	start := time.Now()
+	resp, err := http.Get(mbs.FindXMLLink(versionDoc, server.URL))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+	data, err := mbs.ReadDownload(resp, start, hooks)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	dataset, err := mbs.Convert(bytes.NewReader(data))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := mbs.ValidateWithOptions(dataset, mbs.Options{Hooks: hooks}); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// latest version: /downloads-202507
+	// downloaded /downloads-202507/$File/MBS-XML-20250701.XML (320 bytes)
+	// converted item 3 at index 0: fee 19.6
+	// converted item 23 at index 1: fee 42.85
+	// 2 of 3 items valid
+}
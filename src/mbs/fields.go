@@ -0,0 +1,178 @@
+package mbs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Field type definitions
+type FieldType int
+
+const (
+	StringType FieldType = iota
+	BooleanType
+	DateType
+	FloatType
+)
+
+// String returns the name of the field type for logging
+func (t FieldType) String() string {
+	switch t {
+	case BooleanType:
+		return "boolean"
+	case DateType:
+		return "date"
+	case FloatType:
+		return "float"
+	default:
+		return "string"
+	}
+}
+
+// FieldInfo stores information about how to process each field
+type FieldInfo struct {
+	fieldType FieldType
+	required  bool
+	nullable  bool // Empty values become null instead of the type's zero value
+}
+
+// fieldDefinitions defines the type and requirements for each field
+var fieldDefinitions = map[string]FieldInfo{
+	// Required fields
+	"ItemNum":     {StringType, true, false}, // Item number (required)
+	"Description": {StringType, true, false}, // Description (required)
+
+	// Boolean fields (Y/N)
+	"NewItem":          {BooleanType, false, false},
+	"ItemChange":       {BooleanType, false, false},
+	"FeeChange":        {BooleanType, false, false},
+	"BenefitChange":    {BooleanType, false, false},
+	"AnaesChange":      {BooleanType, false, false},
+	"EMSNChange":       {BooleanType, false, false},
+	"DescriptorChange": {BooleanType, false, false},
+	"Anaes":            {BooleanType, false, false},
+
+	// Date fields (DD.MM.YYYY), null when empty
+	"ItemStartDate":        {DateType, false, true},
+	"ItemEndDate":          {DateType, false, true},
+	"FeeStartDate":         {DateType, false, true},
+	"BenefitStartDate":     {DateType, false, true},
+	"DescriptionStartDate": {DateType, false, true},
+	"EMSNStartDate":        {DateType, false, true},
+	"EMSNEndDate":          {DateType, false, true},
+	"QFEStartDate":         {DateType, false, true},
+	"QFEEndDate":           {DateType, false, true},
+	"DerivedFeeStartDate":  {DateType, false, true},
+	"EMSNChangeDate":       {DateType, false, true},
+
+	// Float fields (monetary amounts and percentages)
+	"ScheduleFee":        {FloatType, false, false},
+	"DerivedFee":         {FloatType, false, false},
+	"Benefit75":          {FloatType, false, false},
+	"Benefit85":          {FloatType, false, false},
+	"Benefit100":         {FloatType, false, false},
+	"EMSNPercentageCap":  {FloatType, false, false},
+	"EMSNMaximumCap":     {FloatType, false, false},
+	"EMSNFixedCapAmount": {FloatType, false, false},
+	"EMSNCap":            {FloatType, false, false},
+	"BasicUnits":         {FloatType, false, false},
+
+	// String fields (everything else defaults to string)
+	"Category":        {StringType, false, false},
+	"Group":           {StringType, false, false},
+	"SubGroup":        {StringType, false, false},
+	"SubHeading":      {StringType, false, false},
+	"ItemType":        {StringType, false, false},
+	"SubItemNum":      {StringType, false, false},
+	"BenefitType":     {StringType, false, false},
+	"FeeType":         {StringType, false, false},
+	"ProviderType":    {StringType, false, false},
+	"EMSNDescription": {StringType, false, false},
+}
+
+// monetaryFields lists the FloatType fields that hold dollar amounts.
+// Non-monetary floats (percentages, units) are never scaled to cents.
+var monetaryFields = map[string]bool{
+	"ScheduleFee":        true,
+	"DerivedFee":         true,
+	"Benefit75":          true,
+	"Benefit85":          true,
+	"Benefit100":         true,
+	"EMSNMaximumCap":     true,
+	"EMSNFixedCapAmount": true,
+	"EMSNCap":            true,
+}
+
+// percentageFields lists the FloatType fields that hold percentages
+var percentageFields = map[string]bool{
+	"EMSNPercentageCap": true,
+}
+
+// fieldRange returns the inclusive bounds a field's numeric value must lie within:
+// monetary fields must not be negative and percentages must be between 0 and 100
+func fieldRange(field string) (min float64, max float64, ok bool) {
+	switch {
+	case monetaryFields[field]:
+		return 0, math.Inf(1), true
+	case percentageFields[field]:
+		return 0, 100, true
+	}
+	return 0, 0, false
+}
+
+// DefinedFields returns the names of all fields in the field definitions, sorted
+func DefinedFields() []string {
+	fields := make([]string, 0, len(fieldDefinitions))
+	for field := range fieldDefinitions {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// LookupFieldType returns the type of a defined field and whether the field is defined
+func LookupFieldType(field string) (FieldType, bool) {
+	info, exists := fieldDefinitions[field]
+	return info.fieldType, exists
+}
+
+// IsMonetary reports whether a field holds a dollar amount, which Options.MoneyAsCents scales to cents
+func IsMonetary(field string) bool {
+	return monetaryFields[field]
+}
+
+// FieldDefinition describes a field to add to or replace in the built-in field definitions.
+// Type is one of string, boolean, date or float.
+type FieldDefinition struct {
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
+
+// ParseFieldType returns the field type with the given name
+func ParseFieldType(name string) (FieldType, error) {
+	for _, t := range []FieldType{StringType, BooleanType, DateType, FloatType} {
+		if name == t.String() {
+			return t, nil
+		}
+	}
+	return StringType, fmt.Errorf("unknown field type %q (valid types: string, boolean, date, float)", name)
+}
+
+// OverrideFieldDefinitions merges defs over the built-in field definitions, so new fields in the
+// MBS feed can be typed without a code change. Empty dates become null, as for the built-in date
+// fields. Nothing is changed if any type is unknown. It must be called before converting anything.
+func OverrideFieldDefinitions(defs map[string]FieldDefinition) error {
+	infos := make(map[string]FieldInfo, len(defs))
+	for field, def := range defs {
+		fieldType, err := ParseFieldType(def.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field, err)
+		}
+		infos[field] = FieldInfo{fieldType, def.Required, fieldType == DateType}
+	}
+	for field, info := range infos {
+		fieldDefinitions[field] = info
+	}
+	return nil
+}
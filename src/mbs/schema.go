@@ -0,0 +1,42 @@
+package mbs
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing an item as produced by
+// ValidateWithOptions with the given options. It is generated from the field definitions.
+func JSONSchema(opts Options) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for field, info := range fieldDefinitions {
+		property := map[string]interface{}{}
+		var jsonType string
+		switch info.fieldType {
+		case BooleanType:
+			jsonType = "boolean"
+		case DateType:
+			jsonType = "string"
+			property["format"] = "date"
+		case FloatType:
+			jsonType = "number"
+			if opts.MoneyAsCents && monetaryFields[field] {
+				jsonType = "integer"
+			}
+		default:
+			jsonType = "string"
+		}
+
+		if emptyAsNull(info, opts) {
+			property["type"] = []string{jsonType, "null"}
+		} else {
+			property["type"] = jsonType
+		}
+		properties[field] = property
+	}
+
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "MBS item",
+		"type":       "object",
+		"properties": properties,
+		"required":   requiredFields(opts),
+		// Fields missing from the field definitions are passed through as strings
+		"additionalProperties": map[string]interface{}{"type": "string"},
+	}
+}
@@ -0,0 +1,154 @@
+package mbs
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// monthYearRegex matches the month and year in the text of a version link on the downloads page
+var monthYearRegex = regexp.MustCompile(`(January|February|March|April|May|June|July|August|September|October|November|December)\s+\d{4}`)
+
+// xmlLinkRegex matches links and link text ending in an MBS XML file name
+var xmlLinkRegex = regexp.MustCompile(`(?i)MBS-XML-\d{8}\.XML$`)
+
+// xmlDateRegex captures the date in an MBS XML file name
+var xmlDateRegex = regexp.MustCompile(`MBS-XML-(\d{8})\.XML`)
+
+// VersionMonth returns the month named in the text of a version link on the downloads page,
+// such as "July 2025", and whether the text names one
+func VersionMonth(text string) (time.Time, bool) {
+	match := monthYearRegex.FindString(text)
+	if match == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("January 2006", match)
+	return date, err == nil
+}
+
+// FindLatestLink returns the link to the newest version on the MBS Online downloads page, judged
+// by the month and year in the link text, resolved against baseURL. It returns "" if no link
+// names a month. hooks.OnLatestLinkFound is called with the link when one is found.
+func FindLatestLink(doc *goquery.Document, baseURL string, hooks Hooks) string {
+	var latestLink string
+	var latestDate time.Time
+
+	// Look for links containing dates
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+
+		text := s.Text()
+		slog.Debug("Examining link", "text", text, "href", href)
+
+		// Look for text containing dates
+		if date, ok := VersionMonth(text); ok && (latestDate.IsZero() || date.After(latestDate)) {
+			latestDate = date
+			latestLink = href
+			slog.Debug("Found potential latest link", "href", href, "date", date.Format("January 2006"))
+		}
+	})
+
+	if latestLink == "" {
+		return ""
+	}
+	link := ResolveLink(latestLink, baseURL)
+	if hooks.OnLatestLinkFound != nil {
+		hooks.OnLatestLinkFound(link)
+	}
+	return link
+}
+
+// FindXMLLink returns the MBS XML link on a version download page. When the page lists
+// several (e.g. a correction alongside the original), the one with the newest embedded date wins;
+// links without a date are only used if no dated link is found.
+func FindXMLLink(doc *goquery.Document, baseURL string) string {
+	var xmlLink, xmlDate string
+
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+
+		text := strings.ToLower(s.Text())
+		slog.Debug("Examining download link", "text", text, "href", href)
+
+		// Look for links that match the MBS XML pattern
+		if xmlLinkRegex.MatchString(href) || xmlLinkRegex.MatchString(text) || strings.Contains(text, "mbs-xml") {
+			// If the link contains a File directory, it's likely the correct one
+			if strings.Contains(href, "/$File/") {
+				slog.Debug("Found MBS XML link", "href", href)
+				date, err := XMLLinkDate(href)
+				if err != nil {
+					if xmlDate == "" {
+						xmlLink = href
+					}
+					return
+				}
+				if date >= xmlDate {
+					xmlLink, xmlDate = href, date
+				}
+			}
+		}
+	})
+
+	return ResolveLink(xmlLink, baseURL)
+}
+
+// XMLLinkDate extracts the YYYYMMDD date from an MBS XML file name or link
+func XMLLinkDate(link string) (string, error) {
+	matches := xmlDateRegex.FindStringSubmatch(link)
+	if len(matches) < 2 {
+		return "", fmt.Errorf("no date found in XML link: %s", link)
+	}
+	return matches[1], nil
+}
+
+// ResolveLink resolves a link found on an MBS Online page against the base URL,
+// so root-relative links take the base URL's host and other relative links its directory
+func ResolveLink(link string, baseURL string) string {
+	if link == "" || strings.HasPrefix(link, "http") {
+		return link
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// ReadDownload reads the body of an XML download requested at start. A dropped connection can
+// end the body early without an error, so a body shorter than the declared Content-Length is
+// rejected. hooks.OnDownloadComplete is called once the whole body has been read.
+func ReadDownload(resp *http.Response, start time.Time, hooks Hooks) ([]byte, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XML data: %w", err)
+	}
+	if resp.ContentLength >= 0 && int64(len(data)) != resp.ContentLength {
+		return nil, fmt.Errorf("incomplete XML download: expected %d bytes (Content-Length) but read %d", resp.ContentLength, len(data))
+	}
+
+	if hooks.OnDownloadComplete != nil {
+		var downloadURL string
+		if resp.Request != nil {
+			downloadURL = resp.Request.URL.String()
+		}
+		hooks.OnDownloadComplete(downloadURL, len(data), time.Since(start))
+	}
+	return data, nil
+}
@@ -0,0 +1,183 @@
+package mbs
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testBaseURL = "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads"
+
+// parseHTML parses an HTML fixture as if it had been fetched
+func parseHTML(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("failed to parse HTML fixture: %v", err)
+	}
+	return doc
+}
+
+func TestFindLatestLink(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "latest of several dated links",
+			html: `<a href="https://www.mbsonline.gov.au/Content/downloads-202501">1 January 2025</a>
+<a href="https://www.mbsonline.gov.au/Content/downloads-202507">1 July 2025</a>
+<a href="https://www.mbsonline.gov.au/Content/downloads-202503">1 March 2025</a>
+<a href="https://www.mbsonline.gov.au/Content/about">About the MBS</a>`,
+			want: "https://www.mbsonline.gov.au/Content/downloads-202507",
+		},
+		{
+			name: "later year wins over later month",
+			html: `<a href="/a">November 2024</a><a href="/b">February 2025</a>`,
+			want: "https://www.mbsonline.gov.au/b",
+		},
+		{
+			name: "root-relative link",
+			html: `<a href="/internet/mbsonline/publishing.nsf/Content/downloads-202507">July 2025</a>`,
+			want: "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads-202507",
+		},
+		{
+			name: "relative link",
+			html: `<a href="downloads-202507">July 2025</a>`,
+			want: "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads-202507",
+		},
+		{
+			name: "no dated link",
+			html: `<a href="/about">About</a><a>July 2025</a>`,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		if got := FindLatestLink(parseHTML(t, tt.html), testBaseURL, Hooks{}); got != tt.want {
+			t.Errorf("%s: FindLatestLink = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindXMLLink(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "root-relative $File link",
+			html: `<a href="/internet/mbsonline/publishing.nsf/Content/downloads-202507/$File/MBS-XML-20250701.XML">MBS-XML-20250701.XML</a>`,
+			want: "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads-202507/$File/MBS-XML-20250701.XML",
+		},
+		{
+			name: "relative $File link",
+			html: `<a href="downloads-202507/$File/MBS-XML-20250701.XML">Download XML</a>`,
+			want: "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads-202507/$File/MBS-XML-20250701.XML",
+		},
+		{
+			name: "link text names the file",
+			html: `<a href="/files/$File/download?id=1">MBS-XML-20250701.XML</a>`,
+			want: "https://www.mbsonline.gov.au/files/$File/download?id=1",
+		},
+		{
+			name: "XML link outside a $File directory is ignored",
+			html: `<a href="/mirror/MBS-XML-20250701.XML">MBS-XML-20250701.XML</a>`,
+			want: "",
+		},
+		{
+			name: "only the $File link is used",
+			html: `<a href="/mirror/MBS-XML-20250801.XML">Mirror</a>
+<a href="/Content/$File/MBS-XML-20250701.XML">MBS-XML-20250701.XML</a>`,
+			want: "https://www.mbsonline.gov.au/Content/$File/MBS-XML-20250701.XML",
+		},
+		{
+			name: "other downloads are ignored",
+			html: `<a href="/Content/$File/MBS-PDF-20250701.pdf">PDF</a>`,
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		if got := FindXMLLink(parseHTML(t, tt.html), testBaseURL); got != tt.want {
+			t.Errorf("%s: FindXMLLink = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"", ""},
+		{"https://example.com/MBS-XML-20250701.XML", "https://example.com/MBS-XML-20250701.XML"},
+		{"/Content/$File/MBS-XML-20250701.XML", "https://www.mbsonline.gov.au/Content/$File/MBS-XML-20250701.XML"},
+		{"downloads-202507", "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads-202507"},
+		{"../Content/x", "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/x"},
+	}
+	for _, tt := range tests {
+		if got := ResolveLink(tt.link, testBaseURL); got != tt.want {
+			t.Errorf("ResolveLink(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestFindXMLLinkNewestWins(t *testing.T) {
+	const original = `<a href="/Content/downloads-202507/$File/MBS-XML-20250701.XML">MBS-XML-20250701.XML</a>`
+	const correction = `<a href="/Content/downloads-202507/$File/MBS-XML-20250708.XML">MBS-XML-20250708.XML (corrected)</a>`
+	const undated = `<a href="/Content/downloads-202507/$File/mbs-xml">Download mbs-xml</a>`
+	const want = "https://www.mbsonline.gov.au/Content/downloads-202507/$File/MBS-XML-20250708.XML"
+
+	// The newer file wins whichever order the page lists them in, and over an undated link
+	for _, html := range []string{
+		original + correction,
+		correction + original,
+		undated + original + correction,
+		correction + undated,
+	} {
+		if got := FindXMLLink(parseHTML(t, html), testBaseURL); got != want {
+			t.Errorf("FindXMLLink(%s) = %q, want %q", html, got, want)
+		}
+	}
+
+	// An undated link is used only when there is nothing else
+	if got := FindXMLLink(parseHTML(t, undated), testBaseURL); got != "https://www.mbsonline.gov.au/Content/downloads-202507/$File/mbs-xml" {
+		t.Errorf("FindXMLLink with only an undated link = %q", got)
+	}
+}
+
+func TestReadDownloadContentLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantErr       bool
+	}{
+		{"complete", "<MBS_XML/>", 10, false},
+		{"unknown length", "<MBS_XML/>", -1, false},
+		{"shorter than declared", "<MBS_XML/>", 1000, true},
+	}
+	for _, tt := range tests {
+		completed := false
+		hooks := Hooks{OnDownloadComplete: func(string, int, time.Duration) { completed = true }}
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(tt.body)), ContentLength: tt.contentLength}
+		data, err := ReadDownload(resp, time.Now(), hooks)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "incomplete XML download") {
+				t.Errorf("%s: ReadDownload = %v, want an incomplete download error", tt.name, err)
+			}
+			if completed {
+				t.Errorf("%s: OnDownloadComplete called for an incomplete download", tt.name)
+			}
+			continue
+		}
+		if err != nil || string(data) != tt.body || !completed {
+			t.Errorf("%s: ReadDownload = %q, %v (hook called: %v), want the body", tt.name, data, err, completed)
+		}
+	}
+}
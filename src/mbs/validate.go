@@ -0,0 +1,357 @@
+package mbs
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Checkpointer lets long conversions persist progress so they can be resumed.
+// Validate calls Progress before each item and Add for each converted item.
+type Checkpointer interface {
+	// Resume returns the index of the first item still to be converted, the items converted before it
+	// and the number of items skipped before it for each reason
+	Resume() (next int, items []Item, skipReasons map[string]int)
+	// Progress is called with the index of the item about to be processed and the skip counts so far
+	Progress(next int, skipReasons map[string]int) error
+	// Add is called with each converted item
+	Add(item Item) error
+}
+
+// ValidationSummary holds the item counts produced by Validate
+type ValidationSummary struct {
+	TotalItems     int
+	ValidItems     int
+	FieldCount     int
+	Fields         []string       // Unique fields across all items, sorted
+	UnknownFields  []string       // Fields in the data that are not in the field definitions
+	MissingFields  []string       // Fields in the field definitions that no item has
+	SkipReasons    map[string]int // Number of items skipped for each reason
+	DuplicateItems int            // Number of valid items whose ItemNum was already used by an earlier item
+	FilterReasons  map[string]int // Number of valid items dropped by a filter for each reason
+}
+
+// FilteredItems returns the number of valid items dropped by filters after validation
+func (s ValidationSummary) FilteredItems() int {
+	total := 0
+	for _, count := range s.FilterReasons {
+		total += count
+	}
+	return total
+}
+
+// SkippedItems returns the number of items dropped during validation
+func (s ValidationSummary) SkippedItems() int {
+	return s.TotalItems - s.ValidItems
+}
+
+// SchemaDrifted reports whether the data's fields differ from the field definitions
+func (s ValidationSummary) SchemaDrifted() bool {
+	return len(s.UnknownFields) > 0 || len(s.MissingFields) > 0
+}
+
+// detectSchemaDrift compares the fields found in the data with fieldDefinitions
+func detectSchemaDrift(allFields map[string]bool) (unknown []string, missing []string) {
+	for field := range allFields {
+		if _, exists := fieldDefinitions[field]; !exists {
+			unknown = append(unknown, field)
+		}
+	}
+	for field := range fieldDefinitions {
+		if !allFields[field] {
+			missing = append(missing, field)
+		}
+	}
+	sort.Strings(unknown)
+	sort.Strings(missing)
+	return unknown, missing
+}
+
+// findDuplicateItemNums returns the ItemNums used by more than one item, in order of first
+// duplication, and the number of items that repeat an earlier ItemNum
+func findDuplicateItemNums(items []Item) (duplicates []string, count int) {
+	seen := make(map[string]int)
+	for _, item := range items {
+		itemNum, _ := item["ItemNum"].(string)
+		seen[itemNum]++
+		if seen[itemNum] == 2 {
+			duplicates = append(duplicates, itemNum)
+		}
+		if seen[itemNum] > 1 {
+			count++
+		}
+	}
+	return duplicates, count
+}
+
+// outOfRangeFields returns the fields of a converted item whose numeric value lies outside
+// its fieldRange, sorted. Cents are compared as they are, since the bounds are 0 and infinity.
+func outOfRangeFields(item Item) []string {
+	var fields []string
+	for field, value := range item {
+		min, max, ok := fieldRange(field)
+		if !ok {
+			continue
+		}
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		default:
+			continue
+		}
+		if f < min || f > max {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// requiredFields returns the sorted set of fields an item must have: those marked required
+// in fieldDefinitions plus any in opts.RequireFields
+func requiredFields(opts Options) []string {
+	set := make(map[string]bool)
+	for field, info := range fieldDefinitions {
+		if info.required {
+			set[field] = true
+		}
+	}
+	for _, field := range opts.RequireFields {
+		if field = strings.TrimSpace(field); field != "" {
+			set[field] = true
+		}
+	}
+
+	var fields []string
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// traceConversion logs the raw value, detected type and converted value of every field of an item
+func traceConversion(index int, rawItem map[string]interface{}, convertedItem map[string]interface{}) {
+	var fields []string
+	for field := range convertedItem {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	log.Printf("Tracing conversion of item %v at index %d", rawItem["ItemNum"], index)
+	for _, field := range fields {
+		fieldType := "string (undefined)"
+		if info, exists := fieldDefinitions[field]; exists {
+			fieldType = info.fieldType.String()
+		}
+		raw, exists := rawItem[field]
+		if !exists {
+			raw = "<missing>"
+		}
+		converted := convertedItem[field]
+		log.Printf("  %s: raw=%q type=%s converted=%#v", field, fmt.Sprint(raw), fieldType, converted)
+	}
+}
+
+// Validate checks the dataset with the default options. See ValidateWithOptions.
+func Validate(d *Dataset) error {
+	return ValidateWithOptions(d, Options{})
+}
+
+// ValidateWithOptions checks the dataset's structure, drops items missing required fields
+// and converts every field of the remaining items to its typed value
+func ValidateWithOptions(d *Dataset, opts Options) error {
+	var summary ValidationSummary
+
+	// Check if the item data is an array
+	items, ok := d.raw.([]interface{})
+	if !ok {
+		return fmt.Errorf("MBS_Items is not an array or is missing")
+	}
+
+	if len(items) == 0 {
+		return fmt.Errorf("MBS_Items array is empty")
+	}
+
+	// First pass: collect all unique fields across all items
+	allFields := make(map[string]bool)
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for field := range itemMap {
+			allFields[field] = true
+		}
+	}
+
+	// Convert allFields to a sorted slice for logging
+	var fieldNames []string
+	for field := range allFields {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+	log.Printf("Found %d unique fields across all items: %v", len(fieldNames), fieldNames)
+
+	summary.UnknownFields, summary.MissingFields = detectSchemaDrift(allFields)
+	if summary.SchemaDrifted() {
+		log.Printf("Warning: Schema drift detected: new fields %v, missing fields %v", summary.UnknownFields, summary.MissingFields)
+	}
+
+	required := requiredFields(opts)
+	log.Printf("Required fields: %v", required)
+
+	// Count skipped items by reason, matching the logged warnings
+	summary.SkipReasons = make(map[string]int)
+	skip := func(reason string) {
+		summary.SkipReasons[reason]++
+	}
+
+	// Second pass: validate and normalize items, picking up after any checkpointed items
+	var validItems []Item
+	start := 0
+	cp := opts.Checkpoint
+	if cp != nil {
+		var resumedSkips map[string]int
+		start, validItems, resumedSkips = cp.Resume()
+		for reason, count := range resumedSkips {
+			summary.SkipReasons[reason] += count
+		}
+	}
+	for i := start; i < len(items); i++ {
+		if cp != nil {
+			if err := cp.Progress(i, summary.SkipReasons); err != nil {
+				return err
+			}
+		}
+
+		item := items[i]
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			log.Printf("Warning: Skipping item at index %d: not an object", i)
+			skip("not an object")
+			continue
+		}
+
+		// Check required fields have non-empty values
+		isValid := true
+		for _, field := range required {
+			value, exists := itemMap[field]
+			if !exists {
+				log.Printf("Warning: Skipping item at index %d: missing required field '%s'", i, field)
+				skip(fmt.Sprintf("missing required field '%s'", field))
+				isValid = false
+				break
+			}
+			strValue, ok := value.(string)
+			if !ok {
+				log.Printf("Warning: Skipping item at index %d: field '%s' is not a string", i, field)
+				skip(fmt.Sprintf("field '%s' is not a string", field))
+				isValid = false
+				break
+			}
+			if strValue == "" {
+				log.Printf("Warning: Skipping item at index %d: required field '%s' is empty", i, field)
+				skip(fmt.Sprintf("required field '%s' is empty", field))
+				isValid = false
+				break
+			}
+		}
+
+		if !isValid {
+			continue
+		}
+
+		// Create new item with converted types
+		newItemMap := make(Item)
+		for field := range allFields {
+			if value, exists := itemMap[field]; exists {
+				// Convert value to string first
+				strValue, ok := value.(string)
+				if !ok {
+					strValue = fmt.Sprintf("%v", value)
+				}
+				if strValue == "" && opts.OmitEmpty {
+					continue
+				}
+				// Convert to appropriate type
+				newItemMap[field] = convertValue(field, strValue, opts)
+			} else if !opts.OmitEmpty {
+				// Handle missing fields with appropriate zero values
+				newItemMap[field] = convertValue(field, "", opts)
+			}
+		}
+
+		// Flag values no valid feed would contain, such as negative fees
+		if invalid := outOfRangeFields(newItemMap); len(invalid) > 0 {
+			for _, field := range invalid {
+				min, max, _ := fieldRange(field)
+				allowed := fmt.Sprintf("between %v and %v", min, max)
+				if math.IsInf(max, 1) {
+					allowed = fmt.Sprintf("at least %v", min)
+				}
+				log.Printf("Warning: Item %v at index %d: field '%s' has value %v, expected %s",
+					itemMap["ItemNum"], i, field, newItemMap[field], allowed)
+			}
+			if opts.Strict {
+				log.Printf("Warning: Skipping item at index %d: field '%s' is out of range", i, invalid[0])
+				skip(fmt.Sprintf("field '%s' is out of range", invalid[0]))
+				continue
+			}
+		}
+
+		if opts.TraceItem != "" && itemMap["ItemNum"] == opts.TraceItem {
+			traceConversion(i, itemMap, newItemMap)
+		}
+
+		// Add the normalized item to our valid items list
+		validItems = append(validItems, newItemMap)
+		if cp != nil {
+			if err := cp.Add(newItemMap); err != nil {
+				return err
+			}
+		}
+		if opts.Hooks.OnItemConverted != nil {
+			opts.Hooks.OnItemConverted(i, newItemMap)
+		}
+	}
+
+	log.Printf("JSON validation completed: %d valid items out of %d total items, %d fields per item",
+		len(validItems), len(items), len(allFields))
+
+	// ItemNum is the key consumers index items by, so a repeated one would silently lose an item
+	duplicates, duplicateCount := findDuplicateItemNums(validItems)
+	if len(duplicates) > 0 {
+		if opts.FailOnDuplicate {
+			return fmt.Errorf("duplicate ItemNum values: %s", strings.Join(duplicates, ", "))
+		}
+		log.Printf("Warning: Duplicate ItemNum values found (%d repeated items): %s", duplicateCount, strings.Join(duplicates, ", "))
+	}
+
+	// Refuse a dataset where a structural change in the feed dropped too many items
+	skipped := len(items) - len(validItems)
+	skipRatio := float64(skipped) / float64(len(items))
+	if opts.MaxSkipRatio > 0 && skipRatio > opts.MaxSkipRatio {
+		return fmt.Errorf("%d of %d items were skipped (ratio %.4f exceeds maximum %.4f)",
+			skipped, len(items), skipRatio, opts.MaxSkipRatio)
+	}
+
+	summary.TotalItems = len(items)
+	summary.ValidItems = len(validItems)
+	summary.FieldCount = len(allFields)
+	summary.DuplicateItems = duplicateCount
+	summary.Fields = fieldNames
+
+	d.items = validItems
+	d.summary = summary
+	d.validated = true
+	if opts.Hooks.OnValidationComplete != nil {
+		opts.Hooks.OnValidationComplete(summary)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package mbs
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Validation logs every skipped item and field list, which would bury the test output
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestConvertAndValidate(t *testing.T) {
+	const xml = `<MBS_XML>
+<Data>
+	<ItemNum>23</ItemNum>
+	<Description>Standard consultation</Description>
+	<Category>1</Category>
+	<NewItem>N</NewItem>
+	<ItemStartDate>01.12.1989</ItemStartDate>
+	<ScheduleFee>42.85</ScheduleFee>
+	<Benefit100>42.85</Benefit100>
+</Data>
+<Data>
+	<ItemNum>104</ItemNum>
+	<Description>Specialist referral</Description>
+	<Category>1</Category>
+	<NewItem>Y</NewItem>
+	<ItemStartDate></ItemStartDate>
+	<ScheduleFee>1,000.00</ScheduleFee>
+	<Benefit100></Benefit100>
+</Data>
+<Data>
+	<ItemNum>3</ItemNum>
+	<Description></Description>
+</Data>
+</MBS_XML>`
+
+	dataset, err := Convert(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if err := Validate(dataset); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	want := []Item{
+		{
+			"ItemNum":       "23",
+			"Description":   "Standard consultation",
+			"Category":      "1",
+			"NewItem":       false,
+			"ItemStartDate": "1989-12-01",
+			"ScheduleFee":   42.85,
+			"Benefit100":    42.85,
+		},
+		{
+			"ItemNum":       "104",
+			"Description":   "Specialist referral",
+			"Category":      "1",
+			"NewItem":       true,
+			"ItemStartDate": nil,
+			"ScheduleFee":   1000.0,
+			"Benefit100":    0.0,
+		},
+	}
+	if got := dataset.Items(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Items() = %#v, want %#v", got, want)
+	}
+
+	summary := dataset.Summary()
+	if summary.TotalItems != 3 || summary.ValidItems != 2 || summary.SkippedItems() != 1 {
+		t.Errorf("summary counts = %d total, %d valid, %d skipped, want 3, 2, 1",
+			summary.TotalItems, summary.ValidItems, summary.SkippedItems())
+	}
+	wantSkips := map[string]int{"required field 'Description' is empty": 1}
+	if !reflect.DeepEqual(summary.SkipReasons, wantSkips) {
+		t.Errorf("SkipReasons = %v, want %v", summary.SkipReasons, wantSkips)
+	}
+}
+
+func TestConvertSingleItem(t *testing.T) {
+	const xml = `<MBS_XML><Data><ItemNum>23</ItemNum><Description>Standard consultation</Description><ScheduleFee>42.85</ScheduleFee></Data></MBS_XML>`
+
+	dataset, err := Convert(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	raw, ok := dataset.raw.([]interface{})
+	if !ok || len(raw) != 1 {
+		t.Fatalf("single Data element converted to %#v, want an array of one item", dataset.raw)
+	}
+	if err := Validate(dataset); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	items := dataset.Items()
+	if len(items) != 1 || items[0]["ItemNum"] != "23" || items[0]["ScheduleFee"] != 42.85 {
+		t.Errorf("Items() = %v, want item 23 with fee 42.85", items)
+	}
+}
+
+func TestExtractItemData(t *testing.T) {
+	item := map[string]interface{}{"ItemNum": "23"}
+	tests := []struct {
+		name    string
+		rawJSON map[string]interface{}
+		want    interface{}
+	}{
+		{"single item", map[string]interface{}{"MBS_XML": map[string]interface{}{"Data": item}}, []interface{}{item}},
+		{"item array", map[string]interface{}{"MBS_XML": map[string]interface{}{"Data": []interface{}{item, item}}}, []interface{}{item, item}},
+		{"other root", map[string]interface{}{"Schedule": map[string]interface{}{"Items": []interface{}{item}}}, []interface{}{item}},
+	}
+	for _, tt := range tests {
+		got, err := extractItemData(tt.rawJSON)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: extractItemData = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := extractItemData(map[string]interface{}{"MBS_XML": map[string]interface{}{}}); err == nil {
+		t.Errorf("extractItemData without any items succeeded")
+	}
+}
+
+func TestFindDuplicateItemNums(t *testing.T) {
+	items := []Item{
+		{"ItemNum": "23"},
+		{"ItemNum": "104"},
+		{"ItemNum": "23"},
+		{"ItemNum": "3"},
+		{"ItemNum": "23"},
+	}
+	duplicates, count := findDuplicateItemNums(items)
+	if !reflect.DeepEqual(duplicates, []string{"23"}) || count != 2 {
+		t.Errorf("findDuplicateItemNums = %v, %d, want [23], 2", duplicates, count)
+	}
+
+	if duplicates, count := findDuplicateItemNums(items[:2]); duplicates != nil || count != 0 {
+		t.Errorf("findDuplicateItemNums without duplicates = %v, %d", duplicates, count)
+	}
+}
+
+func TestValidateDuplicateItemNums(t *testing.T) {
+	const xml = `<MBS_XML>
+<Data><ItemNum>23</ItemNum><Description>Standard consultation</Description></Data>
+<Data><ItemNum>23</ItemNum><Description>Standard consultation (repeated)</Description></Data>
+</MBS_XML>`
+
+	dataset, err := Convert(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if err := Validate(dataset); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if summary := dataset.Summary(); summary.DuplicateItems != 1 || summary.ValidItems != 2 {
+		t.Errorf("summary = %d duplicates of %d valid items, want 1 of 2", summary.DuplicateItems, summary.ValidItems)
+	}
+
+	dataset, _ = Convert(strings.NewReader(xml))
+	err = ValidateWithOptions(dataset, Options{FailOnDuplicate: true})
+	if err == nil || !strings.Contains(err.Error(), "duplicate ItemNum values: 23") {
+		t.Errorf("ValidateWithOptions with FailOnDuplicate = %v, want a duplicate ItemNum error", err)
+	}
+}
+
+func TestOutOfRangeFields(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		want []string
+	}{
+		{"in range", Item{"ScheduleFee": 42.85, "EMSNPercentageCap": 80.0, "BasicUnits": -1.0}, nil},
+		{"negative fee", Item{"ScheduleFee": -42.85, "Benefit75": 32.15}, []string{"ScheduleFee"}},
+		{"negative cents", Item{"ScheduleFee": int64(-4285)}, []string{"ScheduleFee"}},
+		{"percentage over 100", Item{"EMSNPercentageCap": 150.0}, []string{"EMSNPercentageCap"}},
+		{"boundaries", Item{"ScheduleFee": 0.0, "EMSNPercentageCap": 100.0}, nil},
+		{"several fields", Item{"EMSNPercentageCap": -5.0, "ScheduleFee": -1.0, "Benefit85": nil}, []string{"EMSNPercentageCap", "ScheduleFee"}},
+		{"non-numeric values", Item{"ScheduleFee": "-1", "Description": "x"}, nil},
+	}
+	for _, tt := range tests {
+		if got := outOfRangeFields(tt.item); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: outOfRangeFields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateStrictSkipsOutOfRange(t *testing.T) {
+	const xml = `<MBS_XML>
+<Data><ItemNum>23</ItemNum><Description>Standard consultation</Description><ScheduleFee>42.85</ScheduleFee></Data>
+<Data><ItemNum>24</ItemNum><Description>Negative fee</Description><ScheduleFee>-42.85</ScheduleFee></Data>
+<Data><ItemNum>25</ItemNum><Description>Cap over 100%</Description><EMSNPercentageCap>150</EMSNPercentageCap></Data>
+</MBS_XML>`
+
+	for _, strict := range []bool{false, true} {
+		dataset, err := Convert(strings.NewReader(xml))
+		if err != nil {
+			t.Fatalf("Convert: %v", err)
+		}
+		if err := ValidateWithOptions(dataset, Options{Strict: strict}); err != nil {
+			t.Fatalf("Validate: %v", err)
+		}
+		summary := dataset.Summary()
+		if !strict {
+			// Out-of-range values are only logged
+			if summary.ValidItems != 3 {
+				t.Errorf("without Strict, %d valid items, want 3", summary.ValidItems)
+			}
+			continue
+		}
+		want := map[string]int{"field 'ScheduleFee' is out of range": 1, "field 'EMSNPercentageCap' is out of range": 1}
+		if summary.ValidItems != 1 || !reflect.DeepEqual(summary.SkipReasons, want) {
+			t.Errorf("with Strict, %d valid items and skip reasons %v, want 1 and %v", summary.ValidItems, summary.SkipReasons, want)
+		}
+	}
+}
+
+func TestValidateMaxSkipRatio(t *testing.T) {
+	// One of four items has no ItemNum, a skip ratio of 0.25
+	const xml = `<MBS_XML>
+<Data><ItemNum>3</ItemNum><Description>Short consultation</Description></Data>
+<Data><ItemNum>23</ItemNum><Description>Standard consultation</Description></Data>
+<Data><ItemNum>36</ItemNum><Description>Long consultation</Description></Data>
+<Data><ItemNum></ItemNum><Description>No item number</Description></Data>
+</MBS_XML>`
+
+	tests := []struct {
+		maxSkipRatio float64
+		wantErr      bool
+	}{
+		{0, false},    // Disabled
+		{0.5, false},  // Below the threshold
+		{0.25, false}, // Exactly at the threshold
+		{0.1, true},
+	}
+	for _, tt := range tests {
+		dataset, err := Convert(strings.NewReader(xml))
+		if err != nil {
+			t.Fatalf("Convert: %v", err)
+		}
+		err = ValidateWithOptions(dataset, Options{MaxSkipRatio: tt.maxSkipRatio})
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("MaxSkipRatio %v: ValidateWithOptions = %v, want nil", tt.maxSkipRatio, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Fatalf("MaxSkipRatio %v: ValidateWithOptions accepted a skip ratio of 0.25", tt.maxSkipRatio)
+		}
+		for _, want := range []string{"1 of 4 items", "ratio 0.2500", "maximum 0.1000"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("MaxSkipRatio %v: error %q does not mention %q", tt.maxSkipRatio, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// mergedFilename is the file -merge writes to in the downloads directory. It has no date,
+// so it is never mistaken for an archived version.
+const mergedFilename = "mbs_combined.json"
+
+// MergedValue is a value a field took and the first version it was seen in
+type MergedValue struct {
+	Value     interface{} `json:"value"`
+	FirstSeen string      `json:"first_seen"`
+}
+
+// MergedItem is an item's fields from the latest version it appeared in, the versions it
+// appeared in, and the values of every field that changed over time
+type MergedItem struct {
+	Fields  map[string]interface{}   `json:"fields"`
+	Dates   []string                 `json:"dates"`
+	History map[string][]MergedValue `json:"history,omitempty"`
+}
+
+// MergedVersions is the -merge output: every archived version combined by ItemNum
+type MergedVersions struct {
+	Versions []string              `json:"versions"`
+	Items    map[string]MergedItem `json:"items"`
+}
+
+// mergeVersions combines the items of every archived version by ItemNum, oldest first
+func mergeVersions(versions []ArchivedVersion) (MergedVersions, error) {
+	merged := MergedVersions{Items: make(map[string]MergedItem)}
+	// values[itemNum][field] holds each value the field took, in version order
+	values := make(map[string]map[string][]MergedValue)
+
+	for _, version := range versions {
+		items, err := loadItems(version.Path)
+		if err != nil {
+			return merged, err
+		}
+		merged.Versions = append(merged.Versions, version.Date)
+
+		for itemNum, item := range indexItems(items) {
+			entry := merged.Items[itemNum]
+			entry.Fields = item
+			entry.Dates = append(entry.Dates, version.Date)
+			merged.Items[itemNum] = entry
+
+			if values[itemNum] == nil {
+				values[itemNum] = make(map[string][]MergedValue)
+			}
+			for field, value := range item {
+				seen := values[itemNum][field]
+				if len(seen) > 0 && valuesEqual(seen[len(seen)-1].Value, value) {
+					continue
+				}
+				values[itemNum][field] = append(seen, MergedValue{Value: value, FirstSeen: version.Date})
+			}
+		}
+		log.Printf("Merged %d items from version %s", len(items), version.Date)
+	}
+
+	// Only fields that changed need a history; the rest are fully described by Fields
+	for itemNum, fields := range values {
+		entry := merged.Items[itemNum]
+		for field, history := range fields {
+			if len(history) < 2 {
+				continue
+			}
+			if entry.History == nil {
+				entry.History = make(map[string][]MergedValue)
+			}
+			entry.History[field] = history
+		}
+		merged.Items[itemNum] = entry
+	}
+	return merged, nil
+}
+
+// writeMergedVersions merges every archived version and writes the result to mbs_combined.json
+// in the downloads directory. It returns the path written.
+func writeMergedVersions() (string, error) {
+	versions, err := listArchivedVersions()
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no archived mbs_*.json files found in %s", downloadPath)
+	}
+
+	merged, err := mergeVersions(versions)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to format merged versions: %w", err)
+	}
+	path := filepath.Join(downloadPath, mergedFilename)
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	log.Printf("Merged %d items across %d versions into %s", len(merged.Items), len(versions), path)
+	return path, nil
+}
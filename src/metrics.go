@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"mbsop/mbs"
+)
+
+// downloadDurationBuckets are the upper bounds in seconds of the download duration histogram
+var downloadDurationBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300}
+
+// runMetrics holds the Prometheus metrics served by -metrics-addr. They are always recorded,
+// which is cheap, and only exposed when the endpoint is enabled.
+type runMetrics struct {
+	mu              sync.Mutex
+	lastSuccess     time.Time
+	itemsProcessed  int
+	skippedItems    int
+	downloadCounts  []int // Per bucket, not cumulative
+	downloadCount   int
+	downloadSeconds float64
+	sinkFailures    map[string]int
+}
+
+// metrics is updated by every cycle
+var metrics = &runMetrics{
+	downloadCounts: make([]int, len(downloadDurationBuckets)),
+	sinkFailures:   make(map[string]int),
+}
+
+// recordSuccess marks the end of a cycle that finished without error, including one that found nothing new
+func (m *runMetrics) recordSuccess() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSuccess = time.Now()
+}
+
+// recordConversion records the valid and skipped item counts of a converted version
+func (m *runMetrics) recordConversion(summary mbs.ValidationSummary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.itemsProcessed = summary.ValidItems
+	m.skippedItems += summary.SkippedItems()
+}
+
+// observeDownload records how long an XML download took
+func (m *runMetrics) observeDownload(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seconds := d.Seconds()
+	for i, bound := range downloadDurationBuckets {
+		if seconds <= bound {
+			m.downloadCounts[i]++
+			break
+		}
+	}
+	m.downloadCount++
+	m.downloadSeconds += seconds
+}
+
+// recordSinkFailure counts a failed delivery to the named sink
+func (m *runMetrics) recordSinkFailure(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sinkFailures[name]++
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format
+func (m *runMetrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lastSuccess float64
+	if !m.lastSuccess.IsZero() {
+		lastSuccess = float64(m.lastSuccess.Unix())
+	}
+	writeMetric(w, "mbsodf_last_success_timestamp_seconds", "gauge", "Unix time of the last cycle that finished without error, 0 if none has", lastSuccess)
+	writeMetric(w, "mbsodf_items_processed", "gauge", "Valid items in the most recently converted version", float64(m.itemsProcessed))
+	writeMetric(w, "mbsodf_skipped_items_total", "counter", "Items skipped during validation", float64(m.skippedItems))
+
+	name := "mbsodf_download_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time taken to download an MBS XML file\n# TYPE %s histogram\n", name, name)
+	cumulative := 0
+	for i, bound := range downloadDurationBuckets {
+		cumulative += m.downloadCounts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, m.downloadCount)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", name, formatFloat(m.downloadSeconds), name, m.downloadCount)
+
+	// Every sink is listed from the start so alerts on increases work from the first failure
+	name = "mbsodf_sink_failures_total"
+	fmt.Fprintf(w, "# HELP %s Failed deliveries to a sink such as exec or webhook\n# TYPE %s counter\n", name, name)
+	for _, sink := range sinkNames {
+		fmt.Fprintf(w, "%s{sink=%q} %d\n", name, sink, m.sinkFailures[sink])
+	}
+}
+
+// writeMetric writes a single unlabelled metric with its help and type lines
+func writeMetric(w io.Writer, name, metricType, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, metricType, name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// serveMetrics listens on addr and serves the metrics at /metrics in the background.
+// The listener is opened before returning so a bad or busy address is reported at startup.
+func serveMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.writeTo(w)
+	})
+
+	log.Printf("Serving Prometheus metrics on http://%s/metrics", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Error: Metrics server stopped: %v", err)
+		}
+	}()
+	return nil
+}
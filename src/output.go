@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"mbsop/mbs"
+)
+
+// outputFormats maps each supported -format value to its file extension
+var outputFormats = map[string]string{
+	"json":   ".json",
+	"csv":    ".csv",
+	"ndjson": ".ndjson",
+	"sqlite": ".sqlite",
+}
+
+// leadingFields are placed first, in this order, when fields are listed for output
+var leadingFields = []string{"ItemNum", "Description"}
+
+// outputContentTypes maps each output format to the MIME type used when it is sent over HTTP
+var outputContentTypes = map[string]string{
+	"json":   "application/json",
+	"csv":    "text/csv",
+	"ndjson": "application/x-ndjson",
+	"sqlite": "application/vnd.sqlite3",
+}
+
+// outputFilename returns the path of the output file for an MBS version in the given format
+func outputFilename(mbsDate string, format string) string {
+	return filepath.Join(downloadPath, fmt.Sprintf("mbs_%s%s", mbsDate, outputFormats[format]))
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as path and renames it
+// into place once it has been flushed to disk. The temporary name never contains the MBS date,
+// so a file left behind by a killed process is not mistaken for a downloaded version.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mbs-output-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzip decompresses gzip data, such as a gzip-encoded download or a -gzip-output file
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// orderedFields returns the fields with leadingFields first and the rest in alphabetical order
+func orderedFields(fields []string) []string {
+	present := make(map[string]bool)
+	for _, field := range fields {
+		present[field] = true
+	}
+
+	var ordered []string
+	for _, field := range leadingFields {
+		if present[field] {
+			ordered = append(ordered, field)
+			delete(present, field)
+		}
+	}
+
+	var rest []string
+	for field := range present {
+		rest = append(rest, field)
+	}
+	sort.Strings(rest)
+	return append(ordered, rest...)
+}
+
+// orderedItem encodes an item as a JSON object with its fields in orderedFields order, so
+// ItemNum and Description lead every object and the output does not change between runs
+type orderedItem map[string]interface{}
+
+func (item orderedItem) MarshalJSON() ([]byte, error) {
+	fields := make([]string, 0, len(item))
+	for field := range item {
+		fields = append(fields, field)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range orderedFields(fields) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(item[field])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// orderedItems wraps each item for encoding in field order
+func orderedItems(items []map[string]interface{}) []orderedItem {
+	ordered := make([]orderedItem, len(items))
+	for i, item := range items {
+		ordered[i] = item
+	}
+	return ordered
+}
+
+// encodeOutput serializes the validated dataset in the requested format
+func encodeOutput(dataset *mbs.Dataset, fields []string, config Config) ([]byte, error) {
+	switch config.format {
+	case "csv":
+		return encodeCSV(dataset.Items(), fields)
+	case "ndjson":
+		return encodeNDJSON(dataset.Items())
+	case "sqlite":
+		return encodeSQLite(dataset.Items(), fields, config.moneyAsCents)
+	default:
+		var prettyJSON bytes.Buffer
+		encoder := json.NewEncoder(&prettyJSON)
+		encoder.SetIndent("", "  ")
+		output := map[string]interface{}{"MBS_Items": orderedItems(dataset.Items())}
+		if err := encoder.Encode(output); err != nil {
+			return nil, err
+		}
+		return prettyJSON.Bytes(), nil
+	}
+}
+
+// encodeCSV writes a header row of the ordered fields followed by one row per item
+func encodeCSV(items []map[string]interface{}, fields []string) ([]byte, error) {
+	columns := orderedFields(fields)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		row := make([]string, len(columns))
+		for i, field := range columns {
+			row[i] = csvValue(item[field])
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvValue renders a typed value as a CSV cell: booleans as true/false, numbers without
+// trailing zeros and null as blank. Dates are already ISO strings.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// encodeNDJSON writes each item as a single JSON line
+func encodeNDJSON(items []map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, item := range orderedItems(items) {
+		if err := encoder.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+const outputFixture = `<Data><ItemNum>23</ItemNum><Description>Standard consultation</Description><Category>1</Category><Group>A1</Group><ScheduleFee>42.85</ScheduleFee><NewItem>N</NewItem><ItemStartDate>01.12.1989</ItemStartDate></Data>
+<Data><ItemNum>3</ItemNum><Description>Short consultation</Description><Category>1</Category><Group>A1</Group><ScheduleFee>19.60</ScheduleFee><NewItem>N</NewItem><ItemStartDate>01.12.1989</ItemStartDate></Data>`
+
+func TestEncodeOutputDeterministic(t *testing.T) {
+	for _, format := range []string{"json", "ndjson", "csv"} {
+		var outputs [][]byte
+		for i := 0; i < 2; i++ {
+			dataset := convertFixture(t, outputFixture)
+			output, err := encodeOutput(dataset, dataset.Summary().Fields, Config{format: format})
+			if err != nil {
+				t.Fatalf("encodeOutput %s: %v", format, err)
+			}
+			outputs = append(outputs, output)
+		}
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Errorf("converting the same XML to %s twice gave different output:\n%s\n%s", format, outputs[0], outputs[1])
+		}
+	}
+}
+
+func TestOrderedItemFieldOrder(t *testing.T) {
+	item := orderedItem{
+		"ScheduleFee": 42.85,
+		"Category":    "1",
+		"Description": "Standard consultation",
+		"ItemNum":     "23",
+		"Anaes":       false,
+	}
+	want := `{"ItemNum":"23","Description":"Standard consultation","Anaes":false,"Category":"1","ScheduleFee":42.85}`
+	for i := 0; i < 10; i++ {
+		got, err := json.Marshal(item)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		if string(got) != want {
+			t.Fatalf("orderedItem encoded as %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const pageCacheName = "page-cache.json"
+
+// errNotModified is returned by fetchPageConditional when the server answers 304 Not Modified
+var errNotModified = errors.New("page not modified")
+
+// PageCache holds the validators of the last downloads page fetched by a successful run
+type PageCache struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// loadPageCache reads the page cache from the downloads directory. A missing or unreadable
+// cache is treated as empty, so the page is fetched in full.
+func loadPageCache() PageCache {
+	var cache PageCache
+	data, err := os.ReadFile(filepath.Join(downloadPath, pageCacheName))
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		slog.Warn("Ignoring unreadable page cache", "error", err)
+		return PageCache{}
+	}
+	return cache
+}
+
+// savePageCache records the validators of a fetched page. Pages served without an ETag or
+// Last-Modified header cannot be revalidated, so nothing is saved for them.
+func savePageCache(cache PageCache) {
+	if cache.ETag == "" && cache.LastModified == "" {
+		return
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(downloadPath, pageCacheName), data, 0644)
+	}
+	if err != nil {
+		slog.Warn("Failed to save page cache", "url", cache.URL, "error", err)
+	}
+}
+
+// fetchPageConditional fetches a page, sending If-None-Match and If-Modified-Since when the
+// cache holds validators for the same URL. It returns errNotModified on a 304 response, and
+// otherwise the parsed page with its new validators.
+func fetchPageConditional(ctx context.Context, pageURL string, config Config, cache PageCache) (*goquery.Document, PageCache, error) {
+	header := http.Header{}
+	if cache.URL == pageURL {
+		if cache.ETag != "" {
+			header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
+	slog.Info("Fetching page", "url", pageURL)
+	resp, err := httpGet(ctx, pageURL, config, header)
+	if err != nil {
+		return nil, cache, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, cache, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, cache, fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, cache, err
+	}
+	return doc, PageCache{
+		URL:          pageURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testPageURL      = "https://www.mbsonline.gov.au/internet/mbsonline/publishing.nsf/Content/downloads"
+	testLastModified = "Wed, 01 Jan 2025 00:00:00 GMT"
+)
+
+// conditionalServer serves a downloads page with validators, answering 304 when the request
+// carries the current ETag. The validators of the last request are stored in sent.
+func conditionalServer(t *testing.T, sent *http.Header, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		*sent = r.Header.Clone()
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", testLastModified)
+		w.Write([]byte(`<a href="/downloads-202507">July 2025</a>`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchPageConditionalValidators(t *testing.T) {
+	var sent http.Header
+	var requests atomic.Int32
+	server := conditionalServer(t, &sent, &requests)
+	config := Config{retries: 1}
+
+	// A cache for another URL is not used
+	other := PageCache{URL: server.URL + "/other", ETag: `"v1"`, LastModified: testLastModified}
+	doc, cache, err := fetchPageConditional(context.Background(), server.URL, config, other)
+	if err != nil {
+		t.Fatalf("fetchPageConditional: %v", err)
+	}
+	if sent.Get("If-None-Match") != "" || sent.Get("If-Modified-Since") != "" {
+		t.Errorf("validators sent for a cache of another URL: %v", sent)
+	}
+	if doc.Find("a").Length() != 1 {
+		t.Errorf("fetched page has %d links, want 1", doc.Find("a").Length())
+	}
+	want := PageCache{URL: server.URL, ETag: `"v1"`, LastModified: testLastModified}
+	if cache != want {
+		t.Errorf("returned cache = %+v, want %+v", cache, want)
+	}
+
+	// The cache of the same URL is revalidated, and a 304 is reported as errNotModified
+	_, _, err = fetchPageConditional(context.Background(), server.URL, config, cache)
+	if !errors.Is(err, errNotModified) {
+		t.Errorf("fetchPageConditional with a matching cache = %v, want errNotModified", err)
+	}
+	if sent.Get("If-None-Match") != `"v1"` || sent.Get("If-Modified-Since") != testLastModified {
+		t.Errorf("validators sent = %q, %q, want the cached ETag and Last-Modified",
+			sent.Get("If-None-Match"), sent.Get("If-Modified-Since"))
+	}
+}
+
+func TestRunCycleNotModified(t *testing.T) {
+	downloadPath = t.TempDir()
+	var sent http.Header
+	var requests atomic.Int32
+	server := conditionalServer(t, &sent, &requests)
+
+	savePageCache(PageCache{URL: server.URL, ETag: `"v1"`})
+	err := runCycle(context.Background(), Config{baseURL: server.URL, retries: 1}, nil, time.Time{})
+	if !errors.Is(err, errUpToDate) {
+		t.Errorf("runCycle with an unchanged downloads page = %v, want errUpToDate", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("server received %d requests, want only the revalidated downloads page", n)
+	}
+}
+
+func TestSavePageCache(t *testing.T) {
+	downloadPath = t.TempDir()
+
+	// Without validators the page cannot be revalidated, so nothing is saved
+	savePageCache(PageCache{URL: testPageURL})
+	if _, err := os.Stat(filepath.Join(downloadPath, pageCacheName)); !os.IsNotExist(err) {
+		t.Errorf("page cache saved for a response without validators (stat: %v)", err)
+	}
+	if cache := loadPageCache(); cache != (PageCache{}) {
+		t.Errorf("loadPageCache = %+v, want an empty cache", cache)
+	}
+
+	for _, cache := range []PageCache{
+		{URL: testPageURL, ETag: `"v1"`},
+		{URL: testPageURL, LastModified: testLastModified},
+	} {
+		savePageCache(cache)
+		if got := loadPageCache(); got != cache {
+			t.Errorf("loadPageCache after saving %+v = %+v", cache, got)
+		}
+	}
+}
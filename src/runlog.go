@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const runLogName = "run.log"
+
+// RunRecord describes the outcome of a single run for the persistent run log
+type RunRecord struct {
+	Timestamp  string   `json:"timestamp"`
+	MBSDate    string   `json:"mbs_date,omitempty"`
+	Action     string   `json:"action"` // downloaded, skipped or failed
+	TotalItems int      `json:"total_items,omitempty"`
+	ValidItems int      `json:"valid_items,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// rotateRunLog shifts run.log to run.log.1, run.log.1 to run.log.2 and so on once
+// the current file reaches maxBytes, deleting anything beyond the keep count
+func rotateRunLog(path string, maxBytes int64, keep int) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < maxBytes {
+		return nil
+	}
+
+	if keep < 1 {
+		return os.Remove(path)
+	}
+
+	// Drop the oldest retained file, then shift the rest up by one
+	oldest := fmt.Sprintf("%s.%d", path, keep)
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := keep - 1; n >= 1; n-- {
+		from := fmt.Sprintf("%s.%d", path, n)
+		to := fmt.Sprintf("%s.%d", path, n+1)
+		if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// writeRunLog appends the record as a JSON line to the run log in the downloads directory.
+// Nothing is written with -dry-run.
+func writeRunLog(config Config, record RunRecord) {
+	if !config.runLog || config.dryRun {
+		return
+	}
+
+	record.Timestamp = time.Now().Format(time.RFC3339)
+	path := filepath.Join(downloadPath, runLogName)
+
+	if err := rotateRunLog(path, config.runLogMaxBytes, config.runLogKeep); err != nil {
+		log.Printf("Warning: Failed to rotate run log: %v", err)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Warning: Failed to encode run log record: %v", err)
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Warning: Failed to open run log: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("Warning: Failed to write run log: %v", err)
+	}
+}
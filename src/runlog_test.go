@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotateRunLogShiftsFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), runLogName)
+	writeTestFile(t, path, "current\n")
+	writeTestFile(t, path+".1", "previous\n")
+	writeTestFile(t, path+".2", "oldest\n")
+
+	if err := rotateRunLog(path, 1, 2); err != nil {
+		t.Fatalf("rotateRunLog: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s still exists after rotation", runLogName)
+	}
+	if got := readTestFile(t, path+".1"); got != "current\n" {
+		t.Errorf("run.log.1 = %q, want the rotated current log", got)
+	}
+	if got := readTestFile(t, path+".2"); got != "previous\n" {
+		t.Errorf("run.log.2 = %q, want the previous run.log.1", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("run.log.3 exists, but only 2 rotated files should be kept")
+	}
+}
+
+func TestRotateRunLogBelowLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), runLogName)
+	writeTestFile(t, path, "small\n")
+
+	if err := rotateRunLog(path, 1024, 3); err != nil {
+		t.Fatalf("rotateRunLog: %v", err)
+	}
+	if got := readTestFile(t, path); got != "small\n" {
+		t.Errorf("run.log = %q, want it left in place below the size limit", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("run.log.1 created although the log was below the size limit")
+	}
+}
+
+func TestRotateRunLogKeepZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), runLogName)
+	writeTestFile(t, path, "current\n")
+
+	if err := rotateRunLog(path, 1, 0); err != nil {
+		t.Fatalf("rotateRunLog: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("run.log kept with a keep count of 0")
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("run.log.1 created with a keep count of 0")
+	}
+}
+
+func TestRotateRunLogMissing(t *testing.T) {
+	if err := rotateRunLog(filepath.Join(t.TempDir(), runLogName), 1, 3); err != nil {
+		t.Errorf("rotateRunLog with no log: %v", err)
+	}
+}
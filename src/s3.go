@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// parseS3URL splits an -upload-s3 URL of the form s3://bucket/prefix into its bucket and key prefix
+func parseS3URL(raw string) (bucket string, prefix string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("expected s3://bucket/prefix, got %q", raw)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// s3ObjectKey returns the key a local file is uploaded to. The file name already contains the
+// MBS date, so each version gets its own object.
+func s3ObjectKey(prefix string, localPath string) string {
+	return path.Join(prefix, filepath.Base(localPath))
+}
+
+// uploadToS3 uploads the output file, and the source XML when -keep-xml saved it, to the
+// -upload-s3 location. Credentials and region come from the standard AWS environment
+// variables, shared config files and instance roles.
+func uploadToS3(ctx context.Context, config Config, result ConversionResult) error {
+	bucket, prefix, err := parseS3URL(config.uploadS3)
+	if err != nil {
+		return err
+	}
+
+	// The SDK builds its own transport so it can apply AWS_CA_BUNDLE; -proxy and -dns are added to it
+	client := awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		configureTransport(transport, config)
+	})
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithHTTPClient(client))
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		// S3-compatible stores generally expect bucket names in the path rather than the host
+		if config.s3Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.s3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	output := &s3.PutObjectInput{ContentType: aws.String(outputContentTypes[config.format])}
+	if config.gzipOutput {
+		output.ContentEncoding = aws.String("gzip")
+	}
+	localPaths := []string{result.OutputPath}
+	inputs := []*s3.PutObjectInput{output}
+	if result.XMLPath != "" {
+		localPaths = append(localPaths, result.XMLPath)
+		inputs = append(inputs, &s3.PutObjectInput{ContentType: aws.String("application/xml")})
+	}
+
+	for i, localPath := range localPaths {
+		input := inputs[i]
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", localPath, err)
+		}
+		// Compress only the uploaded copy; a -gzip-output file is already compressed
+		if config.s3Gzip && input.ContentEncoding == nil {
+			input.Metadata = map[string]string{"uncompressed-size": strconv.Itoa(len(data))}
+			if data, err = gzipBytes(data); err != nil {
+				return fmt.Errorf("failed to compress %s: %w", localPath, err)
+			}
+			input.ContentEncoding = aws.String("gzip")
+		}
+		input.Bucket = aws.String(bucket)
+		input.Key = aws.String(s3ObjectKey(prefix, localPath))
+		input.Body = bytes.NewReader(data)
+		if _, err := s3Client.PutObject(ctx, input); err != nil {
+			return fmt.Errorf("failed to upload %s to s3://%s/%s: %w", localPath, bucket, *input.Key, err)
+		}
+		log.Printf("Uploaded %s to s3://%s/%s", localPath, bucket, *input.Key)
+	}
+	return nil
+}
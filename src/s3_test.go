@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// s3Upload is a PutObject request received by the mock S3 endpoint
+type s3Upload struct {
+	path        string
+	contentType string
+	body        string
+}
+
+// setTestAWSEnv gives the SDK fake credentials and keeps it away from any real config files
+// or instance metadata
+func setTestAWSEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "ap-southeast-2")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func TestUploadToS3(t *testing.T) {
+	setTestAWSEnv(t)
+
+	var mu sync.Mutex
+	var uploads []s3Upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("request to %s is not signed", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploads = append(uploads, s3Upload{r.URL.Path, r.Header.Get("Content-Type"), string(body)})
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "mbs_20250701.json")
+	xmlPath := filepath.Join(dir, "mbs_20250701.xml")
+	os.WriteFile(outputPath, []byte(`{"MBS_Items":[]}`), 0644)
+	os.WriteFile(xmlPath, []byte(`<MBS_XML></MBS_XML>`), 0644)
+
+	config := Config{uploadS3: "s3://mbs-bucket/schedules/", s3Endpoint: server.URL, format: "json"}
+	result := ConversionResult{OutputPath: outputPath, XMLPath: xmlPath}
+	if err := uploadToS3(context.Background(), config, result); err != nil {
+		t.Fatalf("uploadToS3: %v", err)
+	}
+
+	want := []s3Upload{
+		{"/mbs-bucket/schedules/mbs_20250701.json", "application/json", `{"MBS_Items":[]}`},
+		{"/mbs-bucket/schedules/mbs_20250701.xml", "application/xml", `<MBS_XML></MBS_XML>`},
+	}
+	if len(uploads) != len(want) {
+		t.Fatalf("mock S3 received %d uploads, want %d: %+v", len(uploads), len(want), uploads)
+	}
+	for i, upload := range uploads {
+		// Path-style addressing puts the bucket in the path
+		if upload.path != want[i].path || !strings.HasPrefix(upload.contentType, want[i].contentType) {
+			t.Errorf("upload %d = %s (%s), want %s (%s)", i, upload.path, upload.contentType, want[i].path, want[i].contentType)
+		}
+		if upload.body != want[i].body {
+			t.Errorf("upload %d body = %q, want %q", i, upload.body, want[i].body)
+		}
+	}
+}
+
+func TestUploadToS3Failure(t *testing.T) {
+	setTestAWSEnv(t)
+	t.Setenv("AWS_MAX_ATTEMPTS", "1")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+	}))
+	defer server.Close()
+
+	outputPath := filepath.Join(t.TempDir(), "mbs_20250701.json")
+	os.WriteFile(outputPath, []byte("{}"), 0644)
+
+	config := Config{uploadS3: "s3://mbs-bucket", s3Endpoint: server.URL, format: "json"}
+	err := uploadToS3(context.Background(), config, ConversionResult{OutputPath: outputPath})
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("uploadToS3 against a denying endpoint = %v, want an AccessDenied error", err)
+	}
+}
+
+func TestUploadToS3Gzip(t *testing.T) {
+	setTestAWSEnv(t)
+
+	var encoding, uncompressedSize string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		uncompressedSize = r.Header.Get("X-Amz-Meta-Uncompressed-Size")
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer server.Close()
+
+	const content = `{"MBS_Items":[{"ItemNum":"23"}]}`
+	outputPath := filepath.Join(t.TempDir(), "mbs_20250701.json")
+	os.WriteFile(outputPath, []byte(content), 0644)
+
+	config := Config{uploadS3: "s3://mbs-bucket", s3Endpoint: server.URL, format: "json", s3Gzip: true}
+	if err := uploadToS3(context.Background(), config, ConversionResult{OutputPath: outputPath}); err != nil {
+		t.Fatalf("uploadToS3: %v", err)
+	}
+
+	if encoding != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", encoding)
+	}
+	if uncompressedSize != strconv.Itoa(len(content)) {
+		t.Errorf("uncompressed-size metadata = %q, want %d", uncompressedSize, len(content))
+	}
+	uploaded, err := gunzip(body)
+	if err != nil {
+		t.Fatalf("uploaded body is not gzip: %v", err)
+	}
+	if string(uploaded) != content {
+		t.Errorf("decompressed upload = %q, want %q", uploaded, content)
+	}
+
+	// The local output file is left as it was
+	if local, _ := os.ReadFile(outputPath); string(local) != content {
+		t.Errorf("local file = %q after upload, want it unchanged", local)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"mbsop/mbs"
+)
+
+// maxConvertBodyBytes caps the XML accepted by POST /convert
+const maxConvertBodyBytes = 256 << 20
+
+// wildcardFormats is the order in which an Accept wildcard picks a format, JSON first
+var wildcardFormats = []string{"json", "ndjson", "sqlite", "csv"}
+
+// responseFormat picks the output format for a /convert request from its Accept header. The
+// range with the highest q-value wins, the first listed on a tie, and a q-value of 0 rules a type
+// out. A wildcard picks JSON unless it was ruled out. JSON is used when the header is missing.
+func responseFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return "json", true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	excluded := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			excluded[mediaType] = true
+			continue
+		}
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		for _, format := range wildcardFormats {
+			contentType := outputContentTypes[format]
+			if excluded[contentType] {
+				continue
+			}
+			if r.mediaType == contentType || r.mediaType == "*/*" ||
+				(strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(r.mediaType, "*"))) {
+				return format, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkWellFormedXML reads every token so truncated or malformed XML is reported with its line
+// rather than as a conversion error
+func checkWellFormedXML(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeHTTPError responds with a JSON error body
+func writeHTTPError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// convertHandler serves POST /convert: the request body is MBS XML, and the response is the
+// converted items in the format chosen by the Accept header. The conversion, validation and
+// filter flags apply as they do to a download.
+func convertHandler(config Config) http.HandlerFunc {
+	var contentTypes []string
+	for _, contentType := range outputContentTypes {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Strings(contentTypes)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeHTTPError(w, http.StatusMethodNotAllowed, "use POST with the MBS XML as the request body")
+			return
+		}
+		format, ok := responseFormat(r.Header.Get("Accept"))
+		if !ok {
+			writeHTTPError(w, http.StatusNotAcceptable, "unsupported Accept header %q (supported: %s)", r.Header.Get("Accept"), strings.Join(contentTypes, ", "))
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConvertBodyBytes))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeHTTPError(w, http.StatusRequestEntityTooLarge, "request body is larger than %d bytes", maxErr.Limit)
+				return
+			}
+			writeHTTPError(w, http.StatusBadRequest, "failed to read request body: %v", err)
+			return
+		}
+		if err := checkXMLContent(data, 0); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, "invalid MBS XML: %v", err)
+			return
+		}
+		if err := checkWellFormedXML(data); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, "invalid MBS XML: %v", err)
+			return
+		}
+
+		dataset, err := mbs.Convert(bytes.NewReader(data))
+		if err != nil {
+			writeHTTPError(w, http.StatusBadRequest, "invalid MBS XML: %v", err)
+			return
+		}
+		requestConfig := config
+		requestConfig.format = format
+		if err := mbs.ValidateWithOptions(dataset, conversionOptions(requestConfig)); err != nil {
+			writeHTTPError(w, http.StatusUnprocessableEntity, "validation failed: %v", err)
+			return
+		}
+		summary := dataset.Summary()
+		if filter := itemFilter(requestConfig); filter != nil {
+			dataset.FilterWithReason(filter)
+		}
+
+		output, err := encodeOutput(dataset, summary.Fields, requestConfig)
+		if err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, "failed to format %s output: %v", format, err)
+			return
+		}
+		w.Header().Set("Content-Type", outputContentTypes[format])
+		w.Write(output)
+		log.Printf("Converted %d of %d items to %s for %s", summary.ValidItems, summary.TotalItems, strings.ToUpper(format), r.RemoteAddr)
+	}
+}
+
+// serve runs the conversion service on the -serve address until SIGINT or SIGTERM is received,
+// then lets requests in progress finish before returning
+func serve(config Config) error {
+	listener, err := net.Listen("tcp", config.serveAddr)
+	if err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", convertHandler(config))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 30 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		log.Printf("Received shutdown signal, waiting for requests in progress")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		shutdown <- server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving conversions at http://%s/convert", listener.Addr())
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return <-shutdown
+}
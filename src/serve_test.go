@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "json", true},
+		{"*/*", "json", true},
+		{"application/json", "json", true},
+		{"text/csv", "csv", true},
+		{"application/x-ndjson; charset=utf-8", "ndjson", true},
+		{"application/vnd.sqlite3", "sqlite", true},
+		{"text/html, text/csv", "csv", true},
+		{"text/csv, application/json", "csv", true}, // First listed wins on a tie
+		{"text/csv;q=0.5, application/json", "json", true},
+		{"application/json;q=0.2, application/x-ndjson;q=0.9", "ndjson", true},
+		{"application/json;q=0, text/csv", "csv", true},
+		{"application/json;q=0, */*", "ndjson", true},
+		{"text/*", "csv", true},
+		{"application/json;q=0", "", false},
+		{"text/html", "", false},
+		{"text/csv;q=0, text/*", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := responseFormat(tt.accept)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("responseFormat(%q) = %q, %v, want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+const serveFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<MBS_XML>
+<Data><ItemNum>23</ItemNum><Description>Standard consultation</Description><Category>1</Category><NewItem>N</NewItem><ItemStartDate>01.12.1989</ItemStartDate><ScheduleFee>42.85</ScheduleFee></Data>
+<Data><ItemNum>3</ItemNum><Description>Short consultation</Description><Category>1</Category><NewItem>Y</NewItem><ItemStartDate></ItemStartDate><ScheduleFee>19.60</ScheduleFee></Data>
+</MBS_XML>`
+
+// postConvert sends body to the /convert handler with the given Accept header
+func postConvert(config Config, method, accept, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/convert", strings.NewReader(body))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	convertHandler(config).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConvertHandlerJSON(t *testing.T) {
+	rec := postConvert(Config{}, http.MethodPost, "", serveFixture)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var response struct {
+		Items []map[string]interface{} `json:"MBS_Items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"ItemNum": "23", "Description": "Standard consultation", "Category": "1", "NewItem": false, "ItemStartDate": "1989-12-01", "ScheduleFee": 42.85},
+		{"ItemNum": "3", "Description": "Short consultation", "Category": "1", "NewItem": true, "ItemStartDate": nil, "ScheduleFee": 19.6},
+	}
+	if !reflect.DeepEqual(response.Items, want) {
+		t.Errorf("MBS_Items = %v, want %v", response.Items, want)
+	}
+}
+
+func TestConvertHandlerCSV(t *testing.T) {
+	rec := postConvert(Config{}, http.MethodPost, "application/json;q=0, text/csv", serveFixture)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "ItemNum,") {
+		t.Errorf("CSV response = %q, want a header and two rows", rec.Body.String())
+	}
+}
+
+func TestConvertHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		accept string
+		body   string
+		status int
+	}{
+		{"not XML", http.MethodPost, "", "this is not XML", http.StatusBadRequest},
+		{"truncated XML", http.MethodPost, "", serveFixture[:len(serveFixture)/2], http.StatusBadRequest},
+		{"GET", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+		{"unsupported Accept", http.MethodPost, "text/html", serveFixture, http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		rec := postConvert(Config{}, tt.method, tt.accept, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] == "" {
+			t.Errorf("%s: error body = %q, want {\"error\": ...}", tt.name, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Sink is a destination the converted output is delivered to after the JSON file is written.
+// A failing required sink fails the run; other sinks are best-effort and only log a warning.
+type Sink struct {
+	Name     string
+	Required bool
+	Deliver  func() error
+}
+
+// sinkNames lists the sinks that can be marked required with -required-sinks
+var sinkNames = []string{"changelog", "group-by", "exec", "webhook", "s3", "sqlite-db"}
+
+// parseRequiredSinks validates the -required-sinks list and returns it as a set
+func parseRequiredSinks(list string) (map[string]bool, error) {
+	required := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, sinkName := range sinkNames {
+			if name == sinkName {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown sink %q (valid sinks: %s)", name, strings.Join(sinkNames, ", "))
+		}
+		required[name] = true
+	}
+	return required, nil
+}
+
+// buildSinks returns the enabled sinks for a newly written version in delivery order
+func buildSinks(ctx context.Context, config Config, required map[string]bool, mbsDate string, result ConversionResult) []Sink {
+	var sinks []Sink
+	add := func(name string, deliver func() error) {
+		sinks = append(sinks, Sink{Name: name, Required: required[name], Deliver: deliver})
+	}
+
+	if config.changelog {
+		add("changelog", func() error { return appendChangelog(mbsDate, result.Items) })
+	}
+	if config.groupBy != "" {
+		add("group-by", func() error { return writeGroupReport(mbsDate, result.Items, config.groupBy) })
+	}
+	if config.execCmd != "" {
+		add("exec", func() error { return executeCommand(ctx, config.execCmd, mbsDate, result, config.sync) })
+	}
+	if config.webhookURL != "" {
+		add("webhook", func() error { return sendWebhook(ctx, config, result, mbsDate) })
+	}
+	if config.uploadS3 != "" {
+		add("s3", func() error { return uploadToS3(ctx, config, result) })
+	}
+	if config.sqliteDB != "" {
+		add("sqlite-db", func() error {
+			return loadSQLiteDB(config.sqliteDB, mbsDate, result.Items, result.Summary.Fields, config.moneyAsCents)
+		})
+	}
+	return sinks
+}
+
+// deliverSinks runs every sink, even after failures, and returns the errors of all failed sinks
+// along with the names of the failed required sinks
+func deliverSinks(sinks []Sink) (errs []string, failedRequired []string) {
+	for _, sink := range sinks {
+		if err := sink.Deliver(); err != nil {
+			metrics.recordSinkFailure(sink.Name)
+			if sink.Required {
+				log.Printf("Error: Required sink %s failed: %v", sink.Name, err)
+				failedRequired = append(failedRequired, sink.Name)
+			} else {
+				log.Printf("Warning: Sink %s failed: %v", sink.Name, err)
+			}
+			errs = append(errs, fmt.Sprintf("%s failed: %v", sink.Name, err))
+		}
+	}
+	return errs, failedRequired
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"mbsop/mbs"
+)
+
+// sqliteTable is the table the items are written to with -format sqlite
+const sqliteTable = "mbs_items"
+
+// sqliteColumnType maps a field to its SQLite column type. Fields missing from the
+// field definitions are stored as TEXT.
+func sqliteColumnType(field string, moneyAsCents bool) string {
+	fieldType, exists := mbs.LookupFieldType(field)
+	if !exists {
+		return "TEXT"
+	}
+	switch fieldType {
+	case mbs.BooleanType:
+		return "INTEGER"
+	case mbs.FloatType:
+		if moneyAsCents && mbs.IsMonetary(field) {
+			return "INTEGER"
+		}
+		return "REAL"
+	default:
+		return "TEXT"
+	}
+}
+
+// sqliteValue converts a typed value for binding: booleans become 1/0, everything else is stored as is
+func sqliteValue(value interface{}) interface{} {
+	if b, ok := value.(bool); ok {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return value
+}
+
+// quoteIdentifier quotes a column or table name for use in SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteMetadataTable holds key/value pairs describing the database, such as its schema version
+const sqliteMetadataTable = "mbs_metadata"
+
+// migrateSQLite creates the items table with the given columns, or adds any missing columns to
+// an existing one with ALTER TABLE so its rows are kept. The schema version in the metadata
+// table is incremented whenever the table is created or altered, and the new version returned.
+func migrateSQLite(db *sql.DB, columns []string, moneyAsCents bool) (int, error) {
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value TEXT)", quoteIdentifier(sqliteMetadataTable))); err != nil {
+		return 0, fmt.Errorf("failed to create metadata table: %w", err)
+	}
+	var version int
+	err := db.QueryRow(fmt.Sprintf("SELECT CAST(value AS INTEGER) FROM %s WHERE key = 'schema_version'", quoteIdentifier(sqliteMetadataTable))).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	existing, err := sqliteColumns(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var statements []string
+	if len(existing) == 0 {
+		definitions := make([]string, len(columns))
+		for i, field := range columns {
+			definitions[i] = quoteIdentifier(field) + " " + sqliteColumnType(field, moneyAsCents)
+			if field == "ItemNum" {
+				definitions[i] += " PRIMARY KEY"
+			}
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(sqliteTable), strings.Join(definitions, ", ")))
+	} else {
+		for _, field := range columns {
+			if !existing[field] {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+					quoteIdentifier(sqliteTable), quoteIdentifier(field), sqliteColumnType(field, moneyAsCents)))
+			}
+		}
+	}
+	if len(statements) == 0 && version > 0 {
+		return version, nil
+	}
+
+	// Apply the schema change and its version together, so a failure leaves the old schema intact
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to migrate table: %w", err)
+		}
+	}
+	version++
+	if _, err := tx.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (key, value) VALUES ('schema_version', ?)", quoteIdentifier(sqliteMetadataTable)), version); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to record schema version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 {
+		log.Printf("Migrated %s to schema version %d, adding %d columns", sqliteTable, version, len(statements))
+	}
+	return version, nil
+}
+
+// sqliteColumns returns the columns of the items table, or an empty set if it does not exist
+func sqliteColumns(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info(%s)", "'"+sqliteTable+"'"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table columns: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// insertSQLiteItems replaces the contents of the items table with items in a single transaction
+// and records the MBS date they came from
+func insertSQLiteItems(db *sql.DB, columns []string, items []map[string]interface{}, mbsDate string) error {
+	// ItemNum is the primary key, so report duplicates by name before any row is written
+	seen := make(map[string]bool, len(items))
+	var duplicates []string
+	for _, item := range items {
+		itemNum := fmt.Sprint(item["ItemNum"])
+		if seen[itemNum] {
+			duplicates = append(duplicates, itemNum)
+		}
+		seen[itemNum] = true
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate ItemNum values cannot be stored in the SQLite table, which is keyed by ItemNum: %s (use -fail-on-duplicate to reject them during validation)", strings.Join(duplicates, ", "))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", quoteIdentifier(sqliteTable))); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to clear table: %w", err)
+	}
+
+	quoted := make([]string, len(columns))
+	for i, field := range columns {
+		quoted[i] = quoteIdentifier(field)
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(sqliteTable),
+		strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+	stmt, err := tx.Prepare(insertSQL)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, item := range items {
+		values := make([]interface{}, len(columns))
+		for i, field := range columns {
+			values[i] = sqliteValue(item[field])
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert item %v: %w", item["ItemNum"], err)
+		}
+	}
+
+	if mbsDate != "" {
+		if _, err := tx.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (key, value) VALUES ('mbs_date', ?)", quoteIdentifier(sqliteMetadataTable)), mbsDate); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record MBS date: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// encodeSQLite builds a SQLite database with an mbs_items table keyed by ItemNum and returns
+// the database file's contents. Columns cover every defined field plus any other field in the data.
+func encodeSQLite(items []map[string]interface{}, fields []string, moneyAsCents bool) ([]byte, error) {
+	columns := orderedFields(append(mbs.DefinedFields(), fields...))
+
+	tmp, err := os.CreateTemp("", "mbs-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	db, err := sql.Open("sqlite", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if _, err := migrateSQLite(db, columns, moneyAsCents); err != nil {
+		return nil, err
+	}
+	if err := insertSQLiteItems(db, columns, items, ""); err != nil {
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(tmpPath)
+}
+
+// loadSQLiteDB loads a new version's items into the long-lived -sqlite-db database, replacing the
+// previous version's rows. Columns for fields the table does not have yet are added first, so a
+// database created by an older release keeps working as the schedule gains fields.
+func loadSQLiteDB(path string, mbsDate string, items []map[string]interface{}, fields []string, moneyAsCents bool) error {
+	columns := orderedFields(append(mbs.DefinedFields(), fields...))
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer db.Close()
+
+	version, err := migrateSQLite(db, columns, moneyAsCents)
+	if err != nil {
+		return err
+	}
+	if err := insertSQLiteItems(db, columns, items, mbsDate); err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	log.Printf("Loaded %d items of MBS version %s into %s (schema version %d)", len(items), mbsDate, path, version)
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openTestDB opens a SQLite database in a temporary directory, closed when the test ends
+func openTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func sqliteMetadata(t *testing.T, db *sql.DB, key string) string {
+	t.Helper()
+	var value string
+	if err := db.QueryRow("SELECT value FROM mbs_metadata WHERE key = ?", key).Scan(&value); err != nil {
+		t.Fatalf("read metadata %s: %v", key, err)
+	}
+	return value
+}
+
+func TestLoadSQLiteDBUpgradesOldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mbs.sqlite")
+
+	// A database written by an older release: fewer columns and no metadata table
+	old := openTestDB(t, path)
+	if _, err := old.Exec(`CREATE TABLE mbs_items ("ItemNum" TEXT PRIMARY KEY, "Description" TEXT)`); err != nil {
+		t.Fatalf("create old table: %v", err)
+	}
+	if _, err := old.Exec(`INSERT INTO mbs_items VALUES ('3', 'Short consultation')`); err != nil {
+		t.Fatalf("insert old row: %v", err)
+	}
+	old.Close()
+
+	db := openTestDB(t, path)
+	version, err := migrateSQLite(db, orderedFields([]string{"ItemNum", "Description", "ScheduleFee", "Category"}), false)
+	if err != nil {
+		t.Fatalf("migrateSQLite: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("schema version after first migration = %d, want 1", version)
+	}
+
+	columns, err := sqliteColumns(db)
+	if err != nil {
+		t.Fatalf("sqliteColumns: %v", err)
+	}
+	for _, field := range []string{"ItemNum", "Description", "ScheduleFee", "Category"} {
+		if !columns[field] {
+			t.Errorf("column %s missing after migration", field)
+		}
+	}
+
+	var description string
+	var fee sql.NullFloat64
+	if err := db.QueryRow(`SELECT Description, ScheduleFee FROM mbs_items WHERE ItemNum = '3'`).Scan(&description, &fee); err != nil {
+		t.Fatalf("existing row lost in migration: %v", err)
+	}
+	if description != "Short consultation" || fee.Valid {
+		t.Errorf("existing row = (%q, %v), want (\"Short consultation\", NULL)", description, fee)
+	}
+	if got := sqliteMetadata(t, db, "schema_version"); got != "1" {
+		t.Errorf("recorded schema_version = %q, want 1", got)
+	}
+
+	// Migrating again with the same fields leaves the version alone
+	if version, err := migrateSQLite(db, orderedFields([]string{"ItemNum", "Description", "ScheduleFee", "Category"}), false); err != nil || version != 1 {
+		t.Errorf("second migration = (%d, %v), want (1, nil)", version, err)
+	}
+	db.Close()
+
+	// Loading a version through the sink upgrades the table to the full defined field set
+	dataset := convertFixture(t, outputFixture)
+	if err := loadSQLiteDB(path, "20250101", dataset.Items(), dataset.Summary().Fields, false); err != nil {
+		t.Fatalf("loadSQLiteDB: %v", err)
+	}
+	db = openTestDB(t, path)
+	if got := sqliteMetadata(t, db, "schema_version"); got != "2" {
+		t.Errorf("schema_version after load = %q, want 2", got)
+	}
+	if got := sqliteMetadata(t, db, "mbs_date"); got != "20250101" {
+		t.Errorf("mbs_date = %q, want 20250101", got)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM mbs_items`).Scan(&count); err != nil {
+		t.Fatalf("count rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("mbs_items has %d rows after load, want the 2 items of the new version", count)
+	}
+	if err := db.QueryRow(`SELECT ScheduleFee FROM mbs_items WHERE ItemNum = '3'`).Scan(&fee); err != nil || fee.Float64 != 19.60 {
+		t.Errorf("item 3 ScheduleFee = (%v, %v), want 19.60", fee, err)
+	}
+}
+
+func TestEncodeSQLiteRecordsSchemaVersion(t *testing.T) {
+	dataset := convertFixture(t, outputFixture)
+	data, err := encodeSQLite(dataset.Items(), dataset.Summary().Fields, false)
+	if err != nil {
+		t.Fatalf("encodeSQLite: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "mbs.sqlite")
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		t.Fatalf("write database: %v", err)
+	}
+	db := openTestDB(t, path)
+	if got := sqliteMetadata(t, db, "schema_version"); got != "1" {
+		t.Errorf("schema_version = %q, want 1", got)
+	}
+}
+
+func TestEncodeSQLiteDuplicateItemNum(t *testing.T) {
+	items := []map[string]interface{}{
+		{"ItemNum": "23", "Description": "Standard consultation"},
+		{"ItemNum": "3", "Description": "Short consultation"},
+		{"ItemNum": "23", "Description": "Standard consultation (repeated)"},
+	}
+	_, err := encodeSQLite(items, []string{"ItemNum", "Description"}, false)
+	if err == nil {
+		t.Fatal("encodeSQLite accepted a duplicate ItemNum")
+	}
+	for _, want := range []string{"duplicate ItemNum", "23", "-fail-on-duplicate"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("encodeSQLite error = %q, want it to mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "UNIQUE constraint") {
+		t.Errorf("encodeSQLite error = %q, want the duplicate reported before inserting", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"mbsop/mbs"
+)
+
+// StatsReport is the machine-readable validation summary written by -stats-file
+type StatsReport struct {
+	MBSDate        string         `json:"mbs_date"`
+	TotalItems     int            `json:"total_items"`
+	ValidItems     int            `json:"valid_items"`
+	SkippedItems   int            `json:"skipped_items"`
+	SkipReasons    map[string]int `json:"skip_reasons"`
+	DuplicateItems int            `json:"duplicate_items"`
+	FilteredItems  int            `json:"filtered_items"`
+	FilterReasons  map[string]int `json:"filter_reasons"`
+	Fields         []string       `json:"fields"`
+	UnknownFields  []string       `json:"unknown_fields"`
+}
+
+// writeStatsFile writes the validation summary of a converted version to path as JSON
+func writeStatsFile(path string, mbsDate string, summary mbs.ValidationSummary) error {
+	report := StatsReport{
+		MBSDate:        mbsDate,
+		TotalItems:     summary.TotalItems,
+		ValidItems:     summary.ValidItems,
+		SkippedItems:   summary.SkippedItems(),
+		SkipReasons:    summary.SkipReasons,
+		DuplicateItems: summary.DuplicateItems,
+		FilteredItems:  summary.FilteredItems(),
+		FilterReasons:  summary.FilterReasons,
+		Fields:         summary.Fields,
+		UnknownFields:  summary.UnknownFields,
+	}
+	if report.SkipReasons == nil {
+		report.SkipReasons = map[string]int{}
+	}
+	if report.FilterReasons == nil {
+		report.FilterReasons = map[string]int{}
+	}
+	if report.UnknownFields == nil {
+		report.UnknownFields = []string{}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format stats: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	log.Printf("Saved validation stats to %s", path)
+	return nil
+}
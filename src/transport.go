@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpClient is shared by all page fetches and downloads; main replaces it with newHTTPClient
+var httpClient = http.DefaultClient
+
+// retryBaseDelay is the delay before the first retry; it doubles with each further attempt.
+// It is a variable so tests can retry without waiting.
+var retryBaseDelay = time.Second
+
+// shouldRetry reports whether a request failed transiently: a network error or a 5xx response.
+// 4xx responses are not retried.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// retryDelay returns the exponential backoff before the given retry with up to 50% random jitter added
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// sleepContext waits for the delay, returning early with the context's error if it is done first
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// toolVersion is reported in the default User-Agent
+const toolVersion = "1.0"
+
+// defaultUserAgent identifies the tool to the MBS server and webhook receivers
+const defaultUserAgent = "mbsodf/" + toolVersion + " (+https://github.com/braingray/mbsodf)"
+
+// userAgentTransport sets the User-Agent on every request that does not already have one
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// configureTransport installs the -proxy and the -dns fallback resolver on a transport.
+// Without -proxy, the transport's own proxy setting is kept.
+func configureTransport(transport *http.Transport, config Config) error {
+	if config.proxy != "" {
+		proxyURL, err := url.Parse(config.proxy)
+		if err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q", config.proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if config.dnsServer != "" {
+		transport.DialContext = fallbackDialContext(config.dnsServer)
+	}
+	return nil
+}
+
+// newHTTPClient builds the shared client, installing the proxy, the fallback DNS resolver
+// and the User-Agent when configured. Without -proxy, HTTP_PROXY and HTTPS_PROXY are honored.
+func newHTTPClient(config Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := configureTransport(transport, config); err != nil {
+		return nil, err
+	}
+	if proxyURL, err := url.Parse(config.proxy); err == nil && config.proxy != "" {
+		log.Printf("Using proxy %s", proxyURL.Redacted())
+	}
+	if config.dnsServer != "" {
+		log.Printf("Using fallback DNS resolver %s when the system resolver fails", config.dnsServer)
+	}
+
+	userAgent := config.userAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	return &http.Client{Transport: userAgentTransport{userAgent: userAgent, base: transport}}, nil
+}
+
+// fallbackDialContext dials with the system resolver first and, if the lookup fails,
+// resolves the host through the given DNS server and dials the returned addresses
+func fallbackDialContext(dnsServer string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+		dnsServer = net.JoinHostPort(dnsServer, "53")
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, dnsServer)
+		},
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		var dnsErr *net.DNSError
+		if err == nil || !errors.As(err, &dnsErr) {
+			return conn, err
+		}
+
+		host, port, splitErr := net.SplitHostPort(addr)
+		if splitErr != nil {
+			return nil, err
+		}
+		log.Printf("Warning: System resolver failed for %s: %v, trying fallback resolver %s", host, err, dnsServer)
+
+		ips, lookupErr := resolver.LookupIPAddr(ctx, host)
+		if lookupErr != nil {
+			return nil, fmt.Errorf("system and fallback resolvers failed for %s: %w", host, lookupErr)
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, lastErr = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if lastErr == nil {
+				log.Printf("Resolved %s via fallback resolver %s", host, dnsServer)
+				return conn, nil
+			}
+		}
+		return nil, lastErr
+	}
+}
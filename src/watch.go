@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watch runs a cycle immediately and then once every interval until SIGINT or SIGTERM is received.
+// A failed cycle is logged and does not stop the loop. A signal received mid-cycle lets the cycle
+// finish before shutting down, so no output file or sink delivery is cut short. The first signal
+// restores the default signal handling, so a second one kills a cycle that is stuck.
+func watch(config Config, requiredSinks map[string]bool, since time.Time) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Printf("Received shutdown signal, stopping after the current cycle (signal again to exit immediately)")
+	}()
+
+	log.Printf("Watching for new MBS versions every %v", config.interval)
+	for {
+		err := runCycleWithTimeout(config, requiredSinks, since)
+		switch {
+		case err == nil, errors.Is(err, errUpToDate):
+		case errors.Is(err, errSchemaDrift):
+			log.Printf("Warning: Schema drift detected, continuing to watch")
+		default:
+			log.Printf("Error: Cycle failed: %v", err)
+		}
+
+		next := time.Now().Add(config.interval)
+		log.Printf("Next check scheduled for %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(config.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Printf("Stopping watch")
+			return
+		case <-timer.C:
+		}
+	}
+}
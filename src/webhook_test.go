@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostWebhookRetries(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"MBS_Items":[]}` {
+			t.Errorf("attempt %d body = %q", requests.Load()+1, body)
+		}
+		if requests.Add(1) <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := Config{webhookURL: server.URL, webhookRetries: 3, webhookTimeout: 5 * time.Second}
+	if err := postWebhook(context.Background(), config, []byte(`{"MBS_Items":[]}`), "application/json", false); err != nil {
+		t.Fatalf("postWebhook: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server received %d requests, want 3", n)
+	}
+}
+
+func TestPostWebhookGivesUp(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := Config{webhookURL: server.URL, webhookRetries: 2, webhookTimeout: 5 * time.Second}
+	if err := postWebhook(context.Background(), config, []byte("{}"), "application/json", false); err == nil {
+		t.Fatal("postWebhook succeeded against a failing server")
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestPostWebhookSignature(t *testing.T) {
+	const secret = "s3cret"
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"MBS_Items":[]}`))
+	gz.Close()
+
+	for _, tt := range []struct {
+		name    string
+		payload []byte
+		gzipped bool
+	}{
+		{"plain", []byte(`{"MBS_Items":[]}`), false},
+		{"gzipped", compressed.Bytes(), true},
+	} {
+		var verified bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Verify the way a receiver would: an HMAC of the raw body as sent
+			body, _ := io.ReadAll(r.Body)
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			verified = hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(expected))
+		}))
+
+		config := Config{webhookURL: server.URL, webhookRetries: 1, webhookSecret: secret, webhookTimeout: 5 * time.Second}
+		if err := postWebhook(context.Background(), config, tt.payload, "application/json", tt.gzipped); err != nil {
+			t.Errorf("%s: postWebhook: %v", tt.name, err)
+		}
+		if !verified {
+			t.Errorf("%s: X-Signature did not match the HMAC of the received body", tt.name)
+		}
+		server.Close()
+	}
+}
+
+func TestPostWebhookUnsigned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sig := r.Header.Get("X-Signature"); sig != "" {
+			t.Errorf("X-Signature %q sent without -webhook-secret", sig)
+		}
+	}))
+	defer server.Close()
+
+	config := Config{webhookURL: server.URL, webhookRetries: 1, webhookTimeout: 5 * time.Second}
+	if err := postWebhook(context.Background(), config, []byte("{}"), "application/json", false); err != nil {
+		t.Fatalf("postWebhook: %v", err)
+	}
+}
+
+func TestBuildMultipartBody(t *testing.T) {
+	fileData := []byte(`{"items":[]}`)
+	body, contentType, err := buildMultipartBody(fileData, "mbs_20250101.json", "20250101", 5942)
+	if err != nil {
+		t.Fatalf("buildMultipartBody: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("parse content type %q: %v", contentType, err)
+	}
+
+	fields := make(map[string]string)
+	var filename string
+	var content []byte
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part %s: %v", part.FormName(), err)
+		}
+		if part.FormName() == "file" {
+			filename = part.FileName()
+			content = data
+		} else {
+			fields[part.FormName()] = string(data)
+		}
+	}
+
+	if filename != "mbs_20250101.json" {
+		t.Errorf("file part filename = %q, want mbs_20250101.json", filename)
+	}
+	if !bytes.Equal(content, fileData) {
+		t.Errorf("file part content = %q, want %q", content, fileData)
+	}
+	if fields["mbs_date"] != "20250101" {
+		t.Errorf("mbs_date = %q, want 20250101", fields["mbs_date"])
+	}
+	if fields["item_count"] != "5942" {
+		t.Errorf("item_count = %q, want 5942", fields["item_count"])
+	}
+}
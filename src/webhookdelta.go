@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// DeltaChanges lists the items added, removed and changed between two versions
+type DeltaChanges struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	VersionDiff
+}
+
+// DeltaPayload is the -webhook-delta body
+type DeltaPayload struct {
+	Changes DeltaChanges `json:"changes"`
+}
+
+// buildDeltaPayload diffs the new version's items against the newest older archived version.
+// It reports false when there is no older version to compare against.
+func buildDeltaPayload(mbsDate string, items []map[string]interface{}) ([]byte, bool, error) {
+	previous, found, err := previousVersion(mbsDate)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		log.Printf("No previous version to compare against, sending the full output to the webhook")
+		return nil, false, nil
+	}
+
+	oldItems, err := loadItems(previous.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	diff := diffVersions(oldItems, items)
+	payload, err := json.Marshal(DeltaPayload{Changes: DeltaChanges{From: previous.Date, To: mbsDate, VersionDiff: diff}})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to format webhook delta: %w", err)
+	}
+	log.Printf("Sending webhook delta from %s to %s: %d added, %d removed, %d changed",
+		previous.Date, mbsDate, len(diff.Added), len(diff.Removed), len(diff.Changed))
+	return payload, true, nil
+}
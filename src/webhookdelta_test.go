@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildDeltaPayload(t *testing.T) {
+	downloadPath = t.TempDir()
+	archive := func(mbsDate, items string) {
+		path := filepath.Join(downloadPath, "mbs_"+mbsDate+".json")
+		if err := os.WriteFile(path, []byte(`{"MBS_Items":`+items+`}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive("20241101", `[{"ItemNum":"1","ScheduleFee":1}]`)
+	archive("20250101", `[{"ItemNum":"3","ScheduleFee":19.6},{"ItemNum":"23","ScheduleFee":42.85}]`)
+	// A newer archived version must not be used as the baseline
+	archive("20250301", `[]`)
+
+	items := []map[string]interface{}{
+		{"ItemNum": "23", "ScheduleFee": 43.9},
+		{"ItemNum": "36", "ScheduleFee": 80.0},
+	}
+	payload, ok, err := buildDeltaPayload("20250201", items)
+	if err != nil || !ok {
+		t.Fatalf("buildDeltaPayload = %v, %v, want a delta", ok, err)
+	}
+
+	var decoded struct {
+		Changes struct {
+			From    string                   `json:"from"`
+			To      string                   `json:"to"`
+			Added   []map[string]interface{} `json:"added"`
+			Removed []map[string]interface{} `json:"removed"`
+			Changed []ChangedItem            `json:"changed"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("payload is not JSON: %v\n%s", err, payload)
+	}
+	changes := decoded.Changes
+	if changes.From != "20250101" || changes.To != "20250201" {
+		t.Errorf("changes from %q to %q, want 20250101 to 20250201", changes.From, changes.To)
+	}
+	if len(changes.Added) != 1 || changes.Added[0]["ItemNum"] != "36" {
+		t.Errorf("added = %v, want item 36", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0]["ItemNum"] != "3" {
+		t.Errorf("removed = %v, want item 3", changes.Removed)
+	}
+	if len(changes.Changed) != 1 || changes.Changed[0].ItemNum != "23" {
+		t.Errorf("changed = %+v, want item 23", changes.Changed)
+	}
+}
+
+func TestBuildDeltaPayloadWithoutPreviousVersion(t *testing.T) {
+	downloadPath = t.TempDir()
+	// Only the new version itself is archived
+	path := filepath.Join(downloadPath, "mbs_20250101.json")
+	if err := os.WriteFile(path, []byte(`{"MBS_Items":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, ok, err := buildDeltaPayload("20250101", []map[string]interface{}{{"ItemNum": "23"}})
+	if err != nil {
+		t.Fatalf("buildDeltaPayload: %v", err)
+	}
+	if ok || payload != nil {
+		t.Errorf("buildDeltaPayload with no previous version = %s, %v, want the full-output fallback", payload, ok)
+	}
+}